@@ -0,0 +1,134 @@
+package leto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// RPC_PROTOCOL_VERSION is the version of the RPC contract between
+// nodes. It must be increased on any incompatible change of the RPC
+// arguments, including TrackingConfiguration, as net/rpc silently
+// drops unknown fields.
+const RPC_PROTOCOL_VERSION int = 1
+
+// Features a node can support.
+const (
+	// FEATURE_SLAVE_TRACKING: accepts StartTracking from a master,
+	// with a load balancing configuration.
+	FEATURE_SLAVE_TRACKING = "slave-tracking"
+	// FEATURE_NODE_PORTS: reaches its master on the artemis port it
+	// advertises, instead of the default one.
+	FEATURE_NODE_PORTS = "node-ports"
+	// FEATURE_PROFILES: can start experiments from stored profiles.
+	FEATURE_PROFILES = "profiles"
+	// FEATURE_REST_API: serves the REST/JSON API.
+	FEATURE_REST_API = "rest-api"
+	// FEATURE_SCHEDULES: can schedule experiments.
+	FEATURE_SCHEDULES = "schedules"
+	// FEATURE_UPDATE_TRACKING: can change some settings of a running
+	// experiment.
+	FEATURE_UPDATE_TRACKING = "update-tracking"
+)
+
+// Capabilities describes the RPC contract implemented by a node.
+type Capabilities struct {
+	Version  string
+	Protocol int
+	Features []string
+}
+
+// LocalCapabilities returns the Capabilities of this build.
+func LocalCapabilities() Capabilities {
+	return Capabilities{
+		Version:  LETO_VERSION,
+		Protocol: RPC_PROTOCOL_VERSION,
+		Features: []string{
+			FEATURE_SLAVE_TRACKING,
+			FEATURE_NODE_PORTS,
+			FEATURE_PROFILES,
+			FEATURE_REST_API,
+			FEATURE_SCHEDULES,
+			FEATURE_UPDATE_TRACKING,
+		},
+	}
+}
+
+// Has returns true if feature is supported.
+func (c Capabilities) Has(feature string) bool {
+	for _, f := range c.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// IncompatibleError is returned when a peer does not implement the
+// RPC contract or features needed to cooperate with this node.
+type IncompatibleError struct {
+	Node    string
+	Version string
+	Reason  string
+}
+
+func (e *IncompatibleError) Error() string {
+	return fmt.Sprintf("node '%s' runs leto %s, incompatible with leto %s: %s", e.Node, e.Version, LETO_VERSION, e.Reason)
+}
+
+// CheckCompatible returns an *IncompatibleError if c is not the same
+// protocol than this build or lacks one of the required features.
+func (c Capabilities) CheckCompatible(node string, required ...string) error {
+	if c.Protocol != RPC_PROTOCOL_VERSION {
+		return &IncompatibleError{
+			Node:    node,
+			Version: c.Version,
+			Reason:  fmt.Sprintf("RPC protocol version %d (expected: %d), upgrade the older node", c.Protocol, RPC_PROTOCOL_VERSION),
+		}
+	}
+	missing := []string{}
+	for _, f := range required {
+		if c.Has(f) == false {
+			missing = append(missing, f)
+		}
+	}
+	if len(missing) > 0 {
+		return &IncompatibleError{
+			Node:    node,
+			Version: c.Version,
+			Reason:  "missing feature(s) " + strings.Join(missing, ", "),
+		}
+	}
+	return nil
+}
+
+// Capabilities queries the Capabilities of n. Nodes older than the
+// capability negotiation are reported with an *IncompatibleError.
+func (n Node) Capabilities(ctx context.Context) (Capabilities, error) {
+	res := Capabilities{}
+	err := n.Call(ctx, "Leto.Capabilities", &NoArgs{}, &res)
+	var remote *RemoteError
+	if errors.As(err, &remote) == true && strings.Contains(remote.Message, "can't find method") {
+		version := n.Version
+		if len(version) == 0 {
+			version = "unknown"
+		}
+		return res, &IncompatibleError{
+			Node:    n.Name,
+			Version: version,
+			Reason:  "it does not support capability negotiation, upgrade it",
+		}
+	}
+	return res, err
+}
+
+// CheckCompatible queries the Capabilities of n and checks they are
+// compatible with this build and include the required features.
+func (n Node) CheckCompatible(ctx context.Context, required ...string) error {
+	c, err := n.Capabilities(ctx)
+	if err != nil {
+		return err
+	}
+	return c.CheckCompatible(n.Name, required...)
+}
@@ -0,0 +1,70 @@
+package leto
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/rpc"
+
+	. "gopkg.in/check.v1"
+)
+
+type CapabilitiesSuite struct{}
+
+var _ = Suite(&CapabilitiesSuite{})
+
+type capabilitiesService struct {
+	capabilities Capabilities
+}
+
+func (s *capabilitiesService) Capabilities(args *NoArgs, reply *Capabilities) error {
+	*reply = s.capabilities
+	return nil
+}
+
+// serveLeto serves service as "Leto" and returns the node to reach it.
+func serveLeto(c *C, service interface{}) (Node, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	router := rpc.NewServer()
+	c.Assert(router.RegisterName("Leto", service), IsNil)
+	server := &http.Server{Handler: router}
+	go server.Serve(l)
+	return Node{
+		Name:    "test-node",
+		Address: "127.0.0.1",
+		Port:    l.Addr().(*net.TCPAddr).Port,
+		Version: "v0.3.2",
+	}, func() { server.Close() }
+}
+
+func (s *CapabilitiesSuite) TestChecksCompatibility(c *C) {
+	local := LocalCapabilities()
+	c.Check(local.Has(FEATURE_SLAVE_TRACKING), Equals, true)
+	c.Check(local.Has("time-travel"), Equals, false)
+	c.Check(local.CheckCompatible("foo", FEATURE_SLAVE_TRACKING, FEATURE_NODE_PORTS), IsNil)
+
+	older := Capabilities{Version: "v0.3.0", Protocol: RPC_PROTOCOL_VERSION, Features: []string{FEATURE_SLAVE_TRACKING}}
+	c.Check(older.CheckCompatible("foo", FEATURE_SLAVE_TRACKING, FEATURE_NODE_PORTS, FEATURE_PROFILES),
+		ErrorMatches, `node 'foo' runs leto v0.3.0, incompatible with leto .*: missing feature\(s\) node-ports, profiles`)
+
+	older.Protocol = 0
+	err := older.CheckCompatible("foo")
+	c.Check(err, ErrorMatches, `node 'foo' runs leto v0.3.0, incompatible with leto .*: RPC protocol version 0 \(expected: 1\), upgrade the older node`)
+	_, ok := err.(*IncompatibleError)
+	c.Check(ok, Equals, true)
+}
+
+func (s *CapabilitiesSuite) TestNegotiatesWithNodes(c *C) {
+	node, stop := serveLeto(c, &capabilitiesService{capabilities: LocalCapabilities()})
+	defer stop()
+	c.Check(node.CheckCompatible(context.Background(), FEATURE_SLAVE_TRACKING), IsNil)
+
+	// a node older than capabilities negotiation
+	node, stop = serveLeto(c, &RPCTestService{})
+	defer stop()
+	err := node.CheckCompatible(context.Background(), FEATURE_SLAVE_TRACKING)
+	c.Check(err, ErrorMatches, `node 'test-node' runs leto v0.3.2, incompatible with leto .*: it does not support capability negotiation, upgrade it`)
+	_, ok := err.(*IncompatibleError)
+	c.Check(ok, Equals, true)
+}
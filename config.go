@@ -4,11 +4,20 @@ import "time"
 
 const MAJOR_FMT_VERSION int = 0
 const MINOR_FMT_VERSION int = 5
+
+// Default ports of a leto instance, each node can override them and
+// advertises its actual ports over zeroconf.
 const LETO_PORT int = 4000
 const ARTEMIS_IN_PORT int = 4001
 const ARTEMIS_OUT_PORT int = 4002
 
 var LETO_VERSION = "development"
 
+const DEFAULT_CONFIG_PATH = "/etc/default/leto.yml"
+
 const ARTEMIS_MIN_VERSION = "v0.4.0"
 const NODE_CACHE_TTL = 5 * time.Second
+const NODE_BROWSE_WINDOW = 200 * time.Millisecond
+const NODE_BROWSE_MAX_WINDOW = 2 * time.Second
+const NODE_BROWSE_RETRY_DELAY = 100 * time.Millisecond
+const NODE_DISCOVERY_TIMEOUT = 5 * time.Second
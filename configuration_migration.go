@@ -0,0 +1,239 @@
+package leto
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// CONFIGURATION_SCHEMA_VERSION is the version of the YAML layout of
+// TrackingConfiguration. It should be incremented, and a migration
+// added to configurationMigrations, each time a key is renamed.
+const CONFIGURATION_SCHEMA_VERSION int = 1
+
+type yamlMap = map[interface{}]interface{}
+
+// configurationKeyRename renames the key at Path of a raw YAML
+// configuration to To, keeping its value.
+type configurationKeyRename struct {
+	Path []string
+	To   string
+}
+
+// configurationMigrations[i] upgrades a raw YAML configuration from
+// schema version i to i+1. Migrations only rename keys, so that
+// configuration files can be migrated without losing their comments
+// or key order, see MigrateConfigurationText.
+var configurationMigrations = [][]configurationKeyRename{
+	{{Path: []string{"stream", "constant-bit-rate"}, To: "bitrate"}},
+}
+
+func (r configurationKeyRename) toPath() []string {
+	return append(append([]string{}, r.Path[:len(r.Path)-1]...), r.To)
+}
+
+func (r configurationKeyRename) apply(config yamlMap) error {
+	parent := config
+	for _, key := range r.Path[:len(r.Path)-1] {
+		child, ok := parent[key].(yamlMap)
+		if ok == false {
+			return nil
+		}
+		parent = child
+	}
+	key := r.Path[len(r.Path)-1]
+	value, ok := parent[key]
+	if ok == false {
+		return nil
+	}
+	if _, ok := parent[r.To]; ok == true {
+		return fmt.Errorf("both '%s' and '%s' are set",
+			strings.Join(r.Path, "."), strings.Join(r.toPath(), "."))
+	}
+	delete(parent, key)
+	parent[r.To] = value
+	return nil
+}
+
+func configurationSchemaVersion(config yamlMap) (int, error) {
+	v, ok := config["schema-version"]
+	if ok == false || v == nil {
+		return 0, nil
+	}
+	version, ok := v.(int)
+	if ok == false || version < 0 {
+		return -1, fmt.Errorf("invalid schema-version '%v'", v)
+	}
+	return version, nil
+}
+
+// migrateConfiguration parses and upgrades a raw YAML configuration,
+// returning the renames it needed. No renames are returned for a
+// configuration already at CONFIGURATION_SCHEMA_VERSION.
+func migrateConfiguration(data []byte) (yamlMap, []configurationKeyRename, bool, error) {
+	config := yamlMap{}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, nil, false, err
+	}
+	if config == nil {
+		config = yamlMap{}
+	}
+
+	version, err := configurationSchemaVersion(config)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if version > CONFIGURATION_SCHEMA_VERSION {
+		return nil, nil, false, fmt.Errorf("configuration schema-version %d is newer than the supported version %d, please upgrade leto", version, CONFIGURATION_SCHEMA_VERSION)
+	}
+	if version == CONFIGURATION_SCHEMA_VERSION {
+		return config, nil, false, nil
+	}
+
+	var renames []configurationKeyRename
+	for ; version < CONFIGURATION_SCHEMA_VERSION; version++ {
+		for _, r := range configurationMigrations[version] {
+			if err := r.apply(config); err != nil {
+				return nil, nil, false, fmt.Errorf("could not migrate configuration from schema-version %d to %d: %s", version, version+1, err)
+			}
+		}
+		renames = append(renames, configurationMigrations[version]...)
+	}
+	config["schema-version"] = CONFIGURATION_SCHEMA_VERSION
+	return config, renames, true, nil
+}
+
+// MigrateConfiguration upgrades a raw YAML configuration to
+// CONFIGURATION_SCHEMA_VERSION. A configuration without a
+// schema-version is considered to be version 0.
+func MigrateConfiguration(data []byte) ([]byte, error) {
+	config, _, migrated, err := migrateConfiguration(data)
+	if err != nil {
+		return nil, err
+	}
+	if migrated == false {
+		return data, nil
+	}
+	return yaml.Marshal(config)
+}
+
+var (
+	yamlKeyRx         = regexp.MustCompile(`^(\s*)([^\s#'"{}\[\],:-][^#'"{}\[\],:]*?|"[^"]*"|'[^']*')\s*:(\s|$)`)
+	yamlBlockScalarRx = regexp.MustCompile(`^[|>][-+0-9]*\s*(#.*)?$`)
+)
+
+func unquoteYAMLKey(key string) string {
+	if strings.HasPrefix(key, "'") == true {
+		return strings.Trim(key, "'")
+	}
+	if unquoted, err := strconv.Unquote(key); err == nil {
+		return unquoted
+	}
+	return key
+}
+
+func indentation(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+func equalPaths(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// renameConfigurationKeys renames the keys of a block style YAML
+// text line by line, and sets its top level schema-version.
+func renameConfigurationKeys(data []byte, renames []configurationKeyRename) []byte {
+	type parentKey struct {
+		indent int
+		key    string
+	}
+	var parents []parentKey
+	blockScalarIndent := -1
+	hasVersion := false
+
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		indent := indentation(line)
+		if blockScalarIndent >= 0 {
+			if len(trimmed) == 0 || indent > blockScalarIndent {
+				continue
+			}
+			blockScalarIndent = -1
+		}
+		if len(trimmed) == 0 || strings.HasPrefix(trimmed, "#") == true {
+			continue
+		}
+		for len(parents) > 0 && parents[len(parents)-1].indent >= indent {
+			parents = parents[:len(parents)-1]
+		}
+		if strings.HasPrefix(trimmed, "-") == true {
+			// keys within sequences are never renamed
+			parents = append(parents, parentKey{indent, "-"})
+			continue
+		}
+		m := yamlKeyRx.FindStringSubmatchIndex(line)
+		if m == nil {
+			continue
+		}
+		key := unquoteYAMLKey(line[m[4]:m[5]])
+		path := []string{key}
+		for j := len(parents) - 1; j >= 0; j-- {
+			path = append([]string{parents[j].key}, path...)
+		}
+		if equalPaths(path, []string{"schema-version"}) == true {
+			lines[i] = fmt.Sprintf("schema-version: %d", CONFIGURATION_SCHEMA_VERSION)
+			hasVersion = true
+		}
+		for _, r := range renames {
+			if equalPaths(path, r.Path) == true {
+				lines[i] = line[:m[4]] + r.To + line[m[5]:]
+				path = r.toPath()
+				key = r.To
+			}
+		}
+		parents = append(parents, parentKey{indent, key})
+		if yamlBlockScalarRx.MatchString(strings.TrimSpace(line[m[1]:])) == true {
+			blockScalarIndent = indent
+		}
+	}
+
+	res := strings.Join(lines, "\n")
+	if hasVersion == false {
+		res = fmt.Sprintf("schema-version: %d\n", CONFIGURATION_SCHEMA_VERSION) + res
+	}
+	return []byte(res)
+}
+
+// MigrateConfigurationText upgrades a raw YAML configuration like
+// MigrateConfiguration, but only edits the renamed keys and the
+// schema-version, keeping comments and key order. It returns data
+// unchanged if it is already up to date, and an error if the keys to
+// rename cannot be edited line by line, like for flow style YAML.
+func MigrateConfigurationText(data []byte) ([]byte, error) {
+	config, renames, migrated, err := migrateConfiguration(data)
+	if err != nil {
+		return nil, err
+	}
+	if migrated == false {
+		return data, nil
+	}
+	res := renameConfigurationKeys(data, renames)
+	edited := yamlMap{}
+	if err := yaml.Unmarshal(res, &edited); err != nil || reflect.DeepEqual(edited, config) == false {
+		return nil, fmt.Errorf("cannot rename the keys of this YAML layout line by line")
+	}
+	return res, nil
+}
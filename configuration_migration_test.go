@@ -0,0 +1,106 @@
+package leto
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+func (s *ConfigurationSuite) TestMigratesLegacyConfiguration(c *C) {
+	config, err := ParseConfiguration([]byte(`
+experiment: legacy
+stream:
+  constant-bit-rate: 1500
+`))
+	c.Assert(err, IsNil)
+	c.Check(config.SchemaVersion, Equals, CONFIGURATION_SCHEMA_VERSION)
+	c.Check(config.ExperimentName, Equals, "legacy")
+	c.Assert(config.Stream.BitRateKB, Not(IsNil))
+	c.Check(*config.Stream.BitRateKB, Equals, 1500)
+
+	config, err = ParseConfiguration(nil)
+	c.Assert(err, IsNil)
+	c.Check(config.SchemaVersion, Equals, CONFIGURATION_SCHEMA_VERSION)
+}
+
+func (s *ConfigurationSuite) TestMigrationErrors(c *C) {
+	testdata := []struct {
+		YAML     string
+		Expected string
+	}{
+		{
+			"schema-version: 42\n",
+			`configuration schema-version 42 is newer than the supported version 1, please upgrade leto`,
+		},
+		{
+			"schema-version: foo\n",
+			`invalid schema-version 'foo'`,
+		},
+		{
+			"stream:\n  constant-bit-rate: 1500\n  bitrate: 2000\n",
+			`could not migrate configuration from schema-version 0 to 1: both 'stream.constant-bit-rate' and 'stream.bitrate' are set`,
+		},
+	}
+
+	for _, d := range testdata {
+		_, err := ParseConfiguration([]byte(d.YAML))
+		c.Check(err, ErrorMatches, d.Expected)
+	}
+}
+
+func (s *ConfigurationSuite) TestYamlIsVersioned(c *C) {
+	config := &TrackingConfiguration{ExperimentName: "foo"}
+	data, err := config.Yaml()
+	c.Assert(err, IsNil)
+	parsed, err := ParseConfiguration(data)
+	c.Assert(err, IsNil)
+	c.Check(parsed.SchemaVersion, Equals, CONFIGURATION_SCHEMA_VERSION)
+	c.Check(config.SchemaVersion, Equals, 0)
+
+	var nilConfig *TrackingConfiguration = nil
+	data, err = nilConfig.Yaml()
+	c.Check(err, IsNil)
+	c.Check(string(data), Equals, "null\n")
+}
+
+func (s *ConfigurationSuite) TestMigratesConfigurationText(c *C) {
+	legacy := `# legacy configuration
+schema-version: 0
+experiment: legacy # inline comment
+highlights: [1, 2]
+stream:
+  # a comment about the bitrate
+  constant-bit-rate: 1500 # kbit/s
+  host: olympus.local
+camera:
+  constant-bit-rate: |
+    constant-bit-rate: 12
+`
+	migrated, err := MigrateConfigurationText([]byte(legacy))
+	c.Assert(err, IsNil)
+	c.Check(string(migrated), Equals, `# legacy configuration
+schema-version: 1
+experiment: legacy # inline comment
+highlights: [1, 2]
+stream:
+  # a comment about the bitrate
+  bitrate: 1500 # kbit/s
+  host: olympus.local
+camera:
+  constant-bit-rate: |
+    constant-bit-rate: 12
+`)
+
+	migrated, err = MigrateConfigurationText([]byte("stream:\n  constant-bit-rate: 1500\n"))
+	c.Assert(err, IsNil)
+	c.Check(string(migrated), Equals, "schema-version: 1\nstream:\n  bitrate: 1500\n")
+
+	upToDate := "# up to date\nschema-version: 1\nstream: {bitrate: 1500}\n"
+	migrated, err = MigrateConfigurationText([]byte(upToDate))
+	c.Assert(err, IsNil)
+	c.Check(string(migrated), Equals, upToDate)
+
+	_, err = MigrateConfigurationText([]byte("stream: {constant-bit-rate: 1500}\n"))
+	c.Check(err, ErrorMatches, "cannot rename the keys of this YAML layout line by line")
+
+	_, err = MigrateConfigurationText([]byte("stream:\n  constant-bit-rate: 1500\n  bitrate: 2000\n"))
+	c.Check(err, ErrorMatches, "could not migrate configuration from schema-version 0 to 1: both 'stream.constant-bit-rate' and 'stream.bitrate' are set")
+}
@@ -0,0 +1,230 @@
+package leto
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// FieldError reports a semantic error on a single configuration
+// field. Field is the YAML path of the field, like 'camera.fps'.
+type FieldError struct {
+	Field  string
+	Reason string
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Reason)
+}
+
+// FieldErrors is the list of errors reported by Validate().
+type FieldErrors []FieldError
+
+func (e FieldErrors) Error() string {
+	res := make([]string, 0, len(e))
+	for _, fe := range e {
+		res = append(res, fe.Error())
+	}
+	return strings.Join(res, "; ")
+}
+
+// ToError returns nil if there is no errors in the list.
+func (e FieldErrors) ToError() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e *FieldErrors) add(field string, format string, args ...interface{}) {
+	*e = append(*e, FieldError{Field: field, Reason: fmt.Sprintf(format, args...)})
+}
+
+func (e *FieldErrors) merge(prefix string, other FieldErrors) {
+	for _, fe := range other {
+		fe.Field = prefix + "." + fe.Field
+		*e = append(*e, fe)
+	}
+}
+
+// X264Presets are the quality presets accepted by libx264
+var X264Presets = map[string]bool{
+	"ultrafast": true,
+	"superfast": true,
+	"veryfast":  true,
+	"faster":    true,
+	"fast":      true,
+	"medium":    true,
+	"slow":      true,
+	"slower":    true,
+	"veryslow":  true,
+}
+
+// X264Tunes are the tunings accepted by libx264
+var X264Tunes = map[string]bool{
+	"film":        true,
+	"animation":   true,
+	"grain":       true,
+	"stillimage":  true,
+	"fastdecode":  true,
+	"zerolatency": true,
+}
+
+// TagFamilies are the tag families artemis can detect. An empty family
+// disables detection.
+var TagFamilies = map[string]bool{
+	"":              true,
+	"16h5":          true,
+	"25h9":          true,
+	"36h10":         true,
+	"36h11":         true,
+	"36ARTag":       true,
+	"Circle21h7":    true,
+	"Circle49h12":   true,
+	"Custom48h12":   true,
+	"Standard41h12": true,
+	"Standard52h13": true,
+}
+
+// Validate checks the semantic of the set fields. Unset fields are
+// not reported, use CheckAllFieldAreSet() for that purpose.
+func (c *QuadDetectionConfiguration) Validate() FieldErrors {
+	res := FieldErrors{}
+	if c.Decimate != nil && (*c.Decimate < 1.0 || *c.Decimate > 2.0) {
+		res.add("decimate", "%g is outside of [1.0;2.0]", *c.Decimate)
+	}
+	if c.Sigma != nil && *c.Sigma < 0.0 {
+		res.add("sigma", "%g must be positive", *c.Sigma)
+	}
+	if c.MinClusterPixel != nil && *c.MinClusterPixel <= 0 {
+		res.add("min-cluster-pixel", "%d must be strictly positive", *c.MinClusterPixel)
+	}
+	if c.MaxNMaxima != nil && *c.MaxNMaxima <= 0 {
+		res.add("max-n-maxima", "%d must be strictly positive", *c.MaxNMaxima)
+	}
+	if c.CriticalRadian != nil && (*c.CriticalRadian < 0.0 || *c.CriticalRadian >= math.Pi/2.0) {
+		res.add("critical-angle-radian", "%g is outside of [0;π/2[", *c.CriticalRadian)
+	}
+	if c.MaxLineMSE != nil && *c.MaxLineMSE <= 0.0 {
+		res.add("max-line-mean-square-error", "%g must be strictly positive", *c.MaxLineMSE)
+	}
+	if c.MinBWDiff != nil && (*c.MinBWDiff < 0 || *c.MinBWDiff > 255) {
+		res.add("min-black-white-diff", "%d is outside of [0;255]", *c.MinBWDiff)
+	}
+	return res
+}
+
+func (c *TagDetectionConfiguration) Validate() FieldErrors {
+	res := FieldErrors{}
+	if c.Family != nil && TagFamilies[*c.Family] == false {
+		res.add("family", "unknown tag family '%s'", *c.Family)
+	}
+	res.merge("quad", c.Quad.Validate())
+	return res
+}
+
+func (c *CameraConfiguration) Validate() FieldErrors {
+	res := FieldErrors{}
+	if c.FPS != nil && *c.FPS <= 0.0 {
+		res.add("fps", "%g must be strictly positive", *c.FPS)
+	}
+	if c.StrobeDelay != nil && *c.StrobeDelay < 0 {
+		res.add("strobe-delay", "%s must be positive", *c.StrobeDelay)
+	}
+	if c.StrobeDuration != nil && *c.StrobeDuration <= 0 {
+		res.add("strobe-duration", "%s must be strictly positive", *c.StrobeDuration)
+	}
+	if len(res) > 0 || c.FPS == nil || c.StrobeDuration == nil {
+		return res
+	}
+	period := time.Duration(1.0e9 / *c.FPS)
+	strobeEnd := *c.StrobeDuration
+	if c.StrobeDelay != nil {
+		strobeEnd += *c.StrobeDelay
+	}
+	if strobeEnd >= period {
+		res.add("strobe-duration", "strobe ends after %s, but frame period is %s", strobeEnd, period)
+	}
+	return res
+}
+
+func (c *StreamConfiguration) Validate() FieldErrors {
+	res := FieldErrors{}
+	if c.BitRateKB != nil && *c.BitRateKB <= 0 {
+		res.add("bitrate", "%d must be strictly positive", *c.BitRateKB)
+	}
+	if c.BitRateMaxRatio != nil && *c.BitRateMaxRatio < 1.0 {
+		res.add("bitrate-max-ratio", "%g must be greater or equal to 1.0", *c.BitRateMaxRatio)
+	}
+	if c.Quality != nil && X264Presets[*c.Quality] == false {
+		res.add("quality", "unknown libx264 preset '%s'", *c.Quality)
+	}
+	if c.Tune != nil && X264Tunes[*c.Tune] == false {
+		res.add("tuning", "unknown libx264 tuning '%s'", *c.Tune)
+	}
+	return res
+}
+
+func (c *LoadBalancing) Validate() FieldErrors {
+	res := FieldErrors{}
+	known := make(map[string]bool, len(c.UUIDs))
+	for _, uuid := range c.UUIDs {
+		known[uuid] = true
+	}
+	if len(c.SelfUUID) == 0 {
+		res.add("self-UUID", "is not set")
+	} else if known[c.SelfUUID] == false {
+		res.add("self-UUID", "'%s' is not listed in UUIDs", c.SelfUUID)
+	}
+	for i := 0; i < len(c.Assignements); i++ {
+		uuid, ok := c.Assignements[i]
+		if ok == false {
+			res.add("assignation", "no producer assigned for frame %d mod[%d]", i, len(c.Assignements))
+			continue
+		}
+		if known[uuid] == false {
+			res.add("assignation", "frame %d mod[%d] is assigned to unknown UUID '%s'", i, len(c.Assignements), uuid)
+		}
+	}
+	if c.Width < 0 {
+		res.add("width", "%d must be positive", c.Width)
+	}
+	if c.Height < 0 {
+		res.add("height", "%d must be positive", c.Height)
+	}
+	return res
+}
+
+func (c *TrackingConfiguration) Validate() FieldErrors {
+	res := FieldErrors{}
+	if c.NewAntOutputROISize != nil && *c.NewAntOutputROISize <= 0 {
+		res.add("new-ant-roi", "%d must be strictly positive", *c.NewAntOutputROISize)
+	}
+	if c.NewAntRenewPeriod != nil && *c.NewAntRenewPeriod <= 0 {
+		res.add("image-renew-period", "%s must be strictly positive", *c.NewAntRenewPeriod)
+	}
+	if c.Threads != nil && *c.Threads < 0 {
+		res.add("threads", "%d must be positive", *c.Threads)
+	}
+	if c.Highlights != nil {
+		for _, id := range *c.Highlights {
+			if id < 0 || int64(id) > math.MaxUint32 {
+				res.add("highlights", "invalid tag ID %d", id)
+			}
+		}
+	}
+	for _, path := range c.Reset {
+		if _, err := configurationField(reflect.ValueOf(c).Elem(), path, false); err != nil {
+			res.add("reset", "%s", err)
+		}
+	}
+	res.merge("stream", c.Stream.Validate())
+	res.merge("camera", c.Camera.Validate())
+	res.merge("apriltag", c.Detection.Validate())
+	if c.Loads != nil {
+		res.merge("load-balancing", c.Loads.Validate())
+	}
+	return res
+}
@@ -0,0 +1,89 @@
+package leto
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *ConfigurationSuite) TestRecommendedConfigurationIsValid(c *C) {
+	config := RecommendedTrackingConfiguration()
+	c.Check(config.Validate(), HasLen, 0)
+	c.Check(config.Validate().ToError(), IsNil)
+}
+
+func (s *ConfigurationSuite) TestEmptyConfigurationIsValid(c *C) {
+	config := &TrackingConfiguration{}
+	c.Check(config.Validate(), HasLen, 0)
+}
+
+func (s *ConfigurationSuite) TestValidationReportsFieldPath(c *C) {
+	testdata := []struct {
+		Modify   func(*TrackingConfiguration)
+		Expected string
+	}{
+		{
+			func(t *TrackingConfiguration) { *t.Camera.FPS = -3 },
+			`camera.fps: -3 must be strictly positive`,
+		},
+		{
+			func(t *TrackingConfiguration) { *t.Camera.StrobeDuration = 200 * time.Millisecond },
+			`camera.strobe-duration: strobe ends after 200ms, but frame period is 125ms`,
+		},
+		{
+			func(t *TrackingConfiguration) { *t.Detection.Quad.Decimate = 7 },
+			`apriltag.quad.decimate: 7 is outside of \[1.0;2.0\]`,
+		},
+		{
+			func(t *TrackingConfiguration) { *t.Detection.Family = "36h12" },
+			`apriltag.family: unknown tag family '36h12'`,
+		},
+		{
+			func(t *TrackingConfiguration) { *t.Stream.Quality = "best" },
+			`stream.quality: unknown libx264 preset 'best'`,
+		},
+		{
+			func(t *TrackingConfiguration) { *t.Stream.Tune = "none" },
+			`stream.tuning: unknown libx264 tuning 'none'`,
+		},
+		{
+			func(t *TrackingConfiguration) { *t.Highlights = []int{-1} },
+			`highlights: invalid tag ID -1`,
+		},
+		{
+			func(t *TrackingConfiguration) {
+				t.Loads = &LoadBalancing{
+					SelfUUID:     "foo",
+					UUIDs:        map[string]string{"localhost": "foo"},
+					Assignements: map[int]string{0: "foo", 1: "bar"},
+				}
+			},
+			`load-balancing.assignation: frame 1 mod\[2\] is assigned to unknown UUID 'bar'`,
+		},
+		{
+			func(t *TrackingConfiguration) {
+				t.Loads = &LoadBalancing{
+					SelfUUID:     "foo",
+					UUIDs:        map[string]string{"localhost": "foo"},
+					Assignements: map[int]string{0: "foo"},
+					Width:        640,
+					Height:       -480,
+				}
+			},
+			`load-balancing.height: -480 must be positive`,
+		},
+		{
+			func(t *TrackingConfiguration) {
+				*t.Camera.FPS = 0
+				*t.Stream.BitRateKB = 0
+			},
+			`stream.bitrate: 0 must be strictly positive; camera.fps: 0 must be strictly positive`,
+		},
+	}
+
+	for _, d := range testdata {
+		config := RecommendedTrackingConfiguration()
+		d.Modify(&config)
+		c.Check(config.Validate().ToError(), ErrorMatches, d.Expected)
+	}
+}
@@ -0,0 +1,135 @@
+package leto
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Types of the events published by a node.
+const (
+	EVENT_EXPERIMENT_STARTED     = "experiment-started"
+	EVENT_EXPERIMENT_STOPPED     = "experiment-stopped"
+	EVENT_STATE_CHANGED          = "state-changed"
+	EVENT_ARTEMIS_EXITED         = "artemis-exited"
+	EVENT_ARTEMIS_RESTARTED      = "artemis-restarted"
+	EVENT_SLAVE_LOST             = "slave-lost"
+	EVENT_FILE_ROTATED           = "file-rotated"
+	EVENT_STREAM_SEGMENT_CREATED = "stream-segment-created"
+	EVENT_DISK_LOW               = "disk-low"
+	EVENT_DISK_CRITICAL          = "disk-critical"
+	EVENT_CONFIGURATION_UPDATED  = "configuration-updated"
+	EVENT_SCHEDULE_FAILED        = "schedule-failed"
+)
+
+// Event is a state change of a node. Its JSON encoding is part of
+// the REST API and must stay stable.
+type Event struct {
+	// Seq increases by one for each event published by a node since
+	// it started.
+	Seq        uint64    `json:"seq"`
+	Time       time.Time `json:"time"`
+	Type       string    `json:"type"`
+	Node       string    `json:"node"`
+	Experiment string    `json:"experiment,omitempty"`
+	Message    string    `json:"message,omitempty"`
+	// Path is the file concerned by the event, relative to the
+	// experiment directory.
+	Path string `json:"path,omitempty"`
+	// ExitCode is the exit code of artemis for EVENT_ARTEMIS_EXITED.
+	ExitCode *int `json:"exit_code,omitempty"`
+	// State is the new experiment state for EVENT_STATE_CHANGED.
+	State ExperimentState `json:"state,omitempty"`
+}
+
+func (e Event) String() string {
+	res := fmt.Sprintf("%s #%d %s [%s]", e.Time.Format(time.RFC3339), e.Seq, e.Node, e.Type)
+	if len(e.Experiment) > 0 {
+		res += " experiment '" + e.Experiment + "'"
+	}
+	if e.ExitCode != nil {
+		res += fmt.Sprintf(" exit code %d", *e.ExitCode)
+	}
+	if len(e.State) > 0 {
+		res += " now " + string(e.State)
+	}
+	if len(e.Path) > 0 {
+		res += " " + e.Path
+	}
+	if len(e.Message) > 0 {
+		res += ": " + e.Message
+	}
+	return res
+}
+
+// EVENTS_PATH is the REST endpoint streaming events, as one JSON
+// Event per line.
+const EVENTS_PATH = "/api/v1/events"
+
+func (c *RPCClient) httpClient(n Node) (*http.Client, string) {
+	if c.TLSConfig == nil {
+		return http.DefaultClient, "http"
+	}
+	config := c.TLSConfig.Clone()
+	config.ServerName = n.Address
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: config}}, "https"
+}
+
+// FollowEvents streams the events of node n to handler, until ctx is
+// done or the node closes the stream. If since is not nil, events
+// published after the event with that sequence number and still
+// held by the node are sent first, otherwise only new events are.
+func (c *RPCClient) FollowEvents(ctx context.Context, n Node, since *uint64, handler func(Event)) error {
+	client, scheme := c.httpClient(n)
+	url := fmt.Sprintf("%s://%s:%d%s", scheme, n.Address, n.Port, EVENTS_PATH)
+	if since != nil {
+		url += fmt.Sprintf("?since=%d", *since)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if len(c.Token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return &TimeoutError{Node: n.Name, Method: "events", Err: ctx.Err()}
+		}
+		return &UnreachableError{Node: n.Name, Err: err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		r := Response{}
+		if json.NewDecoder(resp.Body).Decode(&r) != nil || len(r.Error) == 0 {
+			r.Error = resp.Status
+		}
+		return &RemoteError{Node: n.Name, Method: "events", Message: r.Error}
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		e := Event{}
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return &RemoteError{Node: n.Name, Method: "events", Message: fmt.Sprintf("invalid event: %s", err)}
+		}
+		handler(e)
+	}
+	if ctx.Err() != nil {
+		return &TimeoutError{Node: n.Name, Method: "events", Err: ctx.Err()}
+	}
+	if err := scanner.Err(); err != nil {
+		return &UnreachableError{Node: n.Name, Err: err}
+	}
+	return &UnreachableError{Node: n.Name, Err: fmt.Errorf("event stream closed")}
+}
+
+// FollowEvents streams the events of n with DefaultRPCClient.
+func (n Node) FollowEvents(ctx context.Context, since *uint64, handler func(Event)) error {
+	return DefaultRPCClient.FollowEvents(ctx, n, since, handler)
+}
@@ -0,0 +1,34 @@
+package leto
+
+import "fmt"
+
+// ExperimentState is the state of the experiment of a node.
+type ExperimentState string
+
+// States of the experiment of a node. A node starts Idle, and ends
+// up Failed if an experiment could not start or ended with an
+// error. A new experiment can be started from Idle and Failed only.
+const (
+	EXPERIMENT_IDLE     ExperimentState = "idle"
+	EXPERIMENT_STARTING ExperimentState = "starting"
+	EXPERIMENT_RUNNING  ExperimentState = "running"
+	EXPERIMENT_STOPPING ExperimentState = "stopping"
+	EXPERIMENT_FAILED   ExperimentState = "failed"
+)
+
+// IsActive returns true if an experiment is set up on the node, i.e.
+// it is starting, running or stopping.
+func (s ExperimentState) IsActive() bool {
+	return s == EXPERIMENT_STARTING || s == EXPERIMENT_RUNNING || s == EXPERIMENT_STOPPING
+}
+
+// StateError is returned when an operation is not allowed in the
+// current experiment state of a node.
+type StateError struct {
+	Operation string
+	State     ExperimentState
+}
+
+func (e *StateError) Error() string {
+	return fmt.Sprintf("cannot %s while the node is %s", e.Operation, e.State)
+}
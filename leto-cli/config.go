@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/formicidae-tracker/leto"
+	"github.com/jessevdk/go-flags"
+)
+
+type ConfigCommand struct {
+}
+
+type ConfigMergeOptions struct {
+	Node Nodename `short:"n" long:"node" description:"node to fetch default configuration from. If not specified, use recommended configuration"`
+}
+
+type ConfigValidateCommand struct {
+	ConfigMergeOptions
+
+	Args struct {
+		ConfigFiles []flags.Filename
+	} `positional-args:"yes" required:"yes"`
+}
+
+type ConfigRenderCommand struct {
+	ConfigMergeOptions
+
+	Args struct {
+		ConfigFiles []flags.Filename
+	} `positional-args:"yes"`
+}
+
+type ConfigDiffCommand struct {
+	Node Nodename `short:"n" long:"node" description:"node whose default configuration both files are merged with before comparing them. If not specified, files are compared as they are"`
+
+	Args struct {
+		From flags.Filename
+		To   flags.Filename
+	} `positional-args:"yes" required:"yes"`
+}
+
+type ConfigMigrateCommand struct {
+	InPlace bool `short:"i" long:"in-place" description:"overwrite files with their migrated version instead of printing them"`
+
+	Args struct {
+		ConfigFiles []flags.Filename
+	} `positional-args:"yes" required:"yes"`
+}
+
+type ConfigExplainCommand struct {
+	Args struct {
+		Field string
+	} `positional-args:"yes"`
+}
+
+func fetchDefaultConfiguration(n *leto.Node) (*leto.TrackingConfiguration, error) {
+	reply := leto.DefaultConfiguration{}
+	ctx, cancel := rpcContext()
+	defer cancel()
+	if err := n.Call(ctx, "Leto.DefaultConfiguration", &leto.NoArgs{}, &reply); err != nil {
+		return nil, err
+	}
+	if len(reply.Error) > 0 {
+		log.Printf("'%s' falls back to recommended defaults: %s", n.Name, reply.Error)
+	}
+	res, err := leto.ParseConfiguration([]byte(reply.YamlConfiguration))
+	if err != nil {
+		return nil, fmt.Errorf("Could not parse '%s' default configuration: %s", n.Name, err)
+	}
+	return res, nil
+}
+
+func (o *ConfigMergeOptions) defaultConfiguration() (*leto.TrackingConfiguration, error) {
+	if len(o.Node) == 0 {
+		res := leto.RecommendedTrackingConfiguration()
+		return &res, nil
+	}
+	n, err := o.Node.GetNode()
+	if err != nil {
+		return nil, err
+	}
+	return fetchDefaultConfiguration(n)
+}
+
+func (o *ConfigMergeOptions) mergeFiles(files []flags.Filename) (*leto.TrackingConfiguration, error) {
+	res, err := o.defaultConfiguration()
+	if err != nil {
+		return nil, err
+	}
+	defaults := &leto.TrackingConfiguration{}
+	if err := defaults.Merge(res); err != nil {
+		return nil, err
+	}
+
+	for _, f := range files {
+		fileConfig, err := leto.ReadConfiguration(string(f))
+		if err != nil {
+			return nil, err
+		}
+		if err := res.MergeWithDefaults(fileConfig, defaults); err != nil {
+			return nil, fmt.Errorf("Could not merge '%s': %s", f, err)
+		}
+	}
+	return res, nil
+}
+
+func (c *ConfigValidateCommand) Execute(args []string) error {
+	hasError := false
+	for _, f := range c.Args.ConfigFiles {
+		fileConfig, err := leto.ReadConfiguration(string(f))
+		if err != nil {
+			return err
+		}
+		for _, fe := range fileConfig.Validate() {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", f, fe)
+			hasError = true
+		}
+	}
+
+	merged, err := c.mergeFiles(c.Args.ConfigFiles)
+	if err != nil {
+		return err
+	}
+	if err := merged.Validate().ToError(); err != nil {
+		return fmt.Errorf("invalid merged configuration: %s", err)
+	}
+
+	if hasError == true {
+		return fmt.Errorf("invalid configuration")
+	}
+
+	fmt.Printf("Configuration is valid\n")
+	return nil
+}
+
+func (c *ConfigRenderCommand) Execute(args []string) error {
+	merged, err := c.mergeFiles(c.Args.ConfigFiles)
+	if err != nil {
+		return err
+	}
+	data, err := merged.Yaml()
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
+
+func formatConfigurationValue(v reflect.Value) string {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "<unset>"
+		}
+		v = v.Elem()
+	}
+	if d, ok := v.Interface().(time.Duration); ok == true {
+		return d.String()
+	}
+	if v.Kind() == reflect.String {
+		return fmt.Sprintf("'%s'", v.String())
+	}
+	return fmt.Sprintf("%v", v.Interface())
+}
+
+// configurations returns the compared configurations, merged with
+// the node default configuration if one is given.
+func (c *ConfigDiffCommand) configurations() (*leto.TrackingConfiguration, *leto.TrackingConfiguration, error) {
+	if len(c.Node) > 0 {
+		o := ConfigMergeOptions{Node: c.Node}
+		from, err := o.mergeFiles([]flags.Filename{c.Args.From})
+		if err != nil {
+			return nil, nil, err
+		}
+		to, err := o.mergeFiles([]flags.Filename{c.Args.To})
+		return from, to, err
+	}
+	from, err := leto.ReadConfiguration(string(c.Args.From))
+	if err != nil {
+		return nil, nil, err
+	}
+	to, err := leto.ReadConfiguration(string(c.Args.To))
+	return from, to, err
+}
+
+func (c *ConfigDiffCommand) Execute(args []string) error {
+	from, to, err := c.configurations()
+	if err != nil {
+		return err
+	}
+
+	changed, err := leto.ChangedConfigurationFields(from, to)
+	if err != nil {
+		return err
+	}
+	fromFields, err := leto.ConfigurationFields(from)
+	if err != nil {
+		return err
+	}
+	toFields, err := leto.ConfigurationFields(to)
+	if err != nil {
+		return err
+	}
+	values := make(map[string][2]reflect.Value, len(fromFields))
+	for i, f := range fromFields {
+		values[f.Path] = [2]reflect.Value{f.Value, toFields[i].Value}
+	}
+	for _, path := range changed {
+		fmt.Printf("%s: %s -> %s\n", path,
+			formatConfigurationValue(values[path][0]),
+			formatConfigurationValue(values[path][1]))
+	}
+	return nil
+}
+
+func (c *ConfigMigrateCommand) Execute(args []string) error {
+	for _, f := range c.Args.ConfigFiles {
+		data, err := ioutil.ReadFile(string(f))
+		if err != nil {
+			return err
+		}
+		// keeps comments and key order whenever possible
+		migrated, err := leto.MigrateConfigurationText(data)
+		if err != nil && c.InPlace == true {
+			return fmt.Errorf("Could not migrate '%s' in place: %s, run without --in-place to print its migrated version", f, err)
+		}
+		if err != nil {
+			migrated, err = leto.MigrateConfiguration(data)
+		}
+		if err != nil {
+			return fmt.Errorf("Could not migrate '%s': %s", f, err)
+		}
+		if c.InPlace == false {
+			fmt.Printf("=== %s ===\n%s", f, migrated)
+			continue
+		}
+		if bytes.Equal(data, migrated) == true {
+			continue
+		}
+		if err := ioutil.WriteFile(string(f), migrated, 0644); err != nil {
+			return err
+		}
+		fmt.Printf("Migrated '%s' to schema-version %d\n", f, leto.CONFIGURATION_SCHEMA_VERSION)
+	}
+	return nil
+}
+
+func (c *ConfigExplainCommand) Execute(args []string) error {
+	config := leto.RecommendedTrackingConfiguration()
+	config.Loads = &leto.LoadBalancing{}
+	fields, err := leto.ConfigurationFields(&config)
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		if strings.HasPrefix(f.Path, c.Args.Field) == false {
+			continue
+		}
+		description := f.Field.Tag.Get("description")
+		if len(description) == 0 {
+			description = "(no description)"
+		}
+		fmt.Printf("%s: %s\n", f.Path, description)
+	}
+	return nil
+}
+
+func init() {
+	configCommand, err := parser.AddCommand("config", "configuration file utilities", "Validates, renders, compares and explains tracking configuration files", &ConfigCommand{})
+	if err != nil {
+		panic(err.Error())
+	}
+	_, err = configCommand.AddCommand("validate", "validates configuration files", "Checks configuration files and their merge with a node's default configuration for errors", &ConfigValidateCommand{})
+	if err != nil {
+		panic(err.Error())
+	}
+	_, err = configCommand.AddCommand("render", "renders a merged configuration", "Prints the configuration that will be used by a node once merged with its default configuration", &ConfigRenderCommand{})
+	if err != nil {
+		panic(err.Error())
+	}
+	_, err = configCommand.AddCommand("diff", "compares two configuration files", "Prints field by field differences between two configuration files. Files are compared as they are, so a field only set in one of them is reported even if the other one gets the same value from the node defaults, unless --node is given to merge both of them with its default configuration", &ConfigDiffCommand{})
+	if err != nil {
+		panic(err.Error())
+	}
+	_, err = configCommand.AddCommand("migrate", "migrates configuration files", "Upgrades configuration files, like an experiment leto-final-config.yml, to the current schema-version", &ConfigMigrateCommand{})
+	if err != nil {
+		panic(err.Error())
+	}
+	_, err = configCommand.AddCommand("explain", "explains configuration fields", "Prints the description of all configuration fields starting with an optional prefix", &ConfigExplainCommand{})
+	if err != nil {
+		panic(err.Error())
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/formicidae-tracker/leto"
+)
+
+type DefaultsCommand struct {
+	Args struct {
+		Node Nodename
+	} `positional-args:"yes" required:"yes"`
+}
+
+var defaultsCommand = &DefaultsCommand{}
+
+func (c *DefaultsCommand) Execute(args []string) error {
+	n, err := c.Args.Node.GetNode()
+	if err != nil {
+		return err
+	}
+
+	defaults := leto.DefaultConfiguration{}
+	ctx, cancel := rpcContext()
+	defer cancel()
+	if err := n.Call(ctx, "Leto.DefaultConfiguration", &leto.NoArgs{}, &defaults); err != nil {
+		return err
+	}
+
+	fmt.Printf("Node: %s\n", n.Name)
+	if defaults.ModTime.IsZero() == true {
+		fmt.Printf("Site Configuration: %s (absent)\n", defaults.Path)
+	} else {
+		fmt.Printf("Site Configuration: %s (modified %s)\n", defaults.Path, defaults.ModTime)
+	}
+	if len(defaults.Error) > 0 {
+		fmt.Printf("Site Configuration Error: %s\n", defaults.Error)
+		fmt.Printf("Falling back to recommended configuration\n")
+	}
+	fmt.Printf("=== Default YAML Configuration START ===\n")
+	fmt.Println(defaults.YamlConfiguration)
+	fmt.Printf("=== Default YAML Configuration END ===\n")
+	return nil
+}
+
+func init() {
+	_, err := parser.AddCommand("defaults", "queries the default configuration of a node", "Queries the default tracking configuration used by a node for unspecified fields", defaultsCommand)
+	if err != nil {
+		panic(err.Error())
+	}
+}
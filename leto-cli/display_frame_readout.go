@@ -5,6 +5,7 @@ import (
 	"net"
 	"os"
 	"os/signal"
+	"strconv"
 	"time"
 
 	"github.com/formicidae-tracker/hermes"
@@ -46,7 +47,9 @@ func (c *DisplayFrameReadoutCommand) Execute(args []string) error {
 	}
 
 	resp := leto.Status{}
-	err = n.RunMethod("Leto.Status", &leto.NoArgs{}, &resp)
+	ctx, cancel := rpcContext()
+	defer cancel()
+	err = n.Call(ctx, "Leto.Status", &leto.NoArgs{}, &resp)
 	if err != nil {
 		return fmt.Errorf("Could not query '%s' status: %s", n.Name, err)
 	}
@@ -54,7 +57,7 @@ func (c *DisplayFrameReadoutCommand) Execute(args []string) error {
 		return fmt.Errorf("'%s' is not running", n.Name)
 	}
 
-	conn, err := net.Dial("tcp", fmt.Sprintf("%s:%d", n.Address, leto.ARTEMIS_OUT_PORT))
+	conn, err := net.Dial("tcp", net.JoinHostPort(n.Address, strconv.Itoa(n.ArtemisOutPort)))
 	if err != nil {
 		return fmt.Errorf("Could not connect to '%s': %s", n.Name, err)
 	}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/formicidae-tracker/leto"
+)
+
+type EventsCommand struct {
+	Since *uint64 `long:"since" description:"also displays the events held by the node after this sequence number"`
+	JSON  bool    `long:"json" description:"displays events as JSON, one per line"`
+	Args  struct {
+		Node Nodename
+	} `positional-args:"yes" required:"yes"`
+}
+
+var eventsCommand = &EventsCommand{}
+
+func (c *EventsCommand) display(e leto.Event) {
+	if c.JSON == false {
+		fmt.Println(e)
+		return
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("Could not encode event: %s", err)
+		return
+	}
+	fmt.Println(string(data))
+}
+
+func (c *EventsCommand) Execute([]string) error {
+	n, err := c.Args.Node.GetNode()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		sigint := make(chan os.Signal, 1)
+		signal.Notify(sigint, os.Interrupt)
+		<-sigint
+		cancel()
+	}()
+
+	since := c.Since
+	for {
+		// on reconnection, the stream resumes after the last
+		// displayed event.
+		var last *leto.Event
+		err := n.FollowEvents(ctx, since, func(e leto.Event) {
+			c.display(e)
+			last = &e
+		})
+		var timeout *leto.TimeoutError
+		if errors.As(err, &timeout) == true {
+			return nil
+		}
+		var remote *leto.RemoteError
+		if errors.As(err, &remote) == true {
+			return err
+		}
+		log.Printf("%s, reconnecting", err)
+		if last != nil && (since == nil || last.Seq > *since) {
+			since = &last.Seq
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func init() {
+	parser.AddCommand("events", "follows the events of a node", "Displays live the events of a node, like started or stopped experiments, artemis exit codes, lost slaves, rotated files and low disk space. It reconnects until interrupted.", eventsCommand)
+}
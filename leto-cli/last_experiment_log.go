@@ -4,7 +4,6 @@ import (
 	"fmt"
 
 	"github.com/formicidae-tracker/leto"
-	"gopkg.in/yaml.v2"
 )
 
 type LastExperimentLogCommand struct {
@@ -22,12 +21,13 @@ func (c *LastExperimentLogCommand) Execute(args []string) error {
 	}
 
 	log := leto.ExperimentLog{}
-	if err := n.RunMethod("Leto.LastExperimentLog", &leto.NoArgs{}, &log); err != nil {
+	ctx, cancel := rpcContext()
+	defer cancel()
+	if err := n.Call(ctx, "Leto.LastExperimentLog", &leto.NoArgs{}, &log); err != nil {
 		return err
 	}
 
-	config := leto.TrackingConfiguration{}
-	err = yaml.Unmarshal([]byte(log.YamlConfiguration), &config)
+	config, err := leto.ParseConfiguration([]byte(log.YamlConfiguration))
 	if err != nil {
 		return fmt.Errorf("Could not parse YAML configuration: %s", err)
 	}
@@ -37,6 +37,7 @@ func (c *LastExperimentLogCommand) Execute(args []string) error {
 	fmt.Printf("Experiment Start Date: %s\n", log.Start)
 	fmt.Printf("Experiment End Date: %s\n", log.End)
 	fmt.Printf("Artemis returned an error: %t\n", log.HasError)
+	printCrashes(log.Crashes)
 
 	fmt.Printf("=== Experiment YAML Configuration START ===\n")
 	fmt.Println(log.YamlConfiguration)
@@ -32,7 +32,9 @@ func (c *LinkingOptions) Execute(args []string) error {
 		Slave:  slave.Name,
 	}
 	resp := &leto.Response{}
-	if err := master.RunMethod(c.command, argsL, resp); err != nil {
+	ctx, cancel := rpcContext()
+	defer cancel()
+	if err := master.Call(ctx, c.command, argsL, resp); err != nil {
 		return err
 	}
 	return resp.ToError()
@@ -1,35 +1,86 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/formicidae-tracker/leto"
 	"github.com/jessevdk/go-flags"
 )
 
 type Options struct {
+	Timeout          time.Duration      `long:"timeout" description:"maximal duration of a request to a node" default:"30s"`
+	DiscoveryTimeout time.Duration      `long:"discovery-timeout" description:"maximal time to look for a node not found right away on the network" default:"2s"`
+	NodeCacheTTL     time.Duration      `long:"node-cache-ttl" env:"LETO_NODE_CACHE_TTL" description:"duration the nodes found on the network are cached without looking for them again" default:"5s"`
+	Nodes            func(string) error `long:"node" value-name:"HOST[:PORT]" description:"uses the leto node at HOST[:PORT] in addition to the ones found on the network, can be repeated"`
+
+	leto.RPCCredentials
+}
+
+// commandLineNodes are the nodes given with --node.
+var commandLineNodes []leto.Node
+
+func addNodeAddress(address string) error {
+	node, err := leto.ParseNodeAddress(address)
+	if err != nil {
+		return err
+	}
+	commandLineNodes = append(commandLineNodes, node)
+	return nil
 }
 
 type Nodename string
 
 var nodes map[string]leto.Node
 
+// knownNodes returns the nodes found on the network and the ones given
+// with --node. Nodes are only listed on first use, once the options,
+// like --node-cache-ttl, are applied.
+func knownNodes() map[string]leto.Node {
+	if nodes != nil {
+		return nodes
+	}
+	var err error
+	nodes, err = leto.NewNodeLister().ListNodes()
+	if err != nil {
+		// nodes could still be given with --node
+		log.Printf("Could not list nodes on local network: %s", err)
+		nodes = make(map[string]leto.Node)
+	}
+	for _, node := range commandLineNodes {
+		if known, ok := nodes[node.Name]; ok == true {
+			node = leto.MergeNodes(known, node)
+		}
+		nodes[node.Name] = node
+	}
+	return nodes
+}
+
 func (n *Nodename) GetNode() (*leto.Node, error) {
 	if len(*n) == 0 {
 		return nil, fmt.Errorf("Missing mandatory node name")
 	}
-	node, ok := nodes[string(*n)]
-	if ok == false {
+	node, ok := knownNodes()[string(*n)]
+	if ok == true {
+		return &node, nil
+	}
+	// the node may be slow to answer, keep looking for it
+	found, err := leto.NewNodeLister().ListExpectedNodes([]string{string(*n)}, opts.DiscoveryTimeout)
+	if err != nil {
 		return nil, fmt.Errorf("Could not find node '%s'", *n)
 	}
+	node = found[string(*n)]
+	nodes[node.Name] = node
 	return &node, nil
 }
 
 func (n *Nodename) Complete(match string) []flags.Completion {
-	res := make([]flags.Completion, 0, len(nodes))
-	for nodeName, node := range nodes {
+	res := make([]flags.Completion, 0, len(knownNodes()))
+	for nodeName, node := range knownNodes() {
 		if strings.HasPrefix(nodeName, match) == false {
 			continue
 		}
@@ -41,18 +92,34 @@ func (n *Nodename) Complete(match string) []flags.Completion {
 	return res
 }
 
-var opts = &Options{}
+// rpcContext returns the context of a single request to a node.
+func rpcContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), opts.Timeout)
+}
+
+var opts = &Options{
+	Nodes: addNodeAddress,
+}
 
 var parser = flags.NewParser(opts, flags.Default)
 
 func Execute() error {
-	var err error
-	nodes, err = leto.NewNodeLister().ListNodes()
-	if err != nil {
-		return fmt.Errorf("Could not list nodes on local network: %s", err)
+	return execute(os.Args[1:])
+}
+
+func execute(args []string) error {
+	parser.CommandHandler = func(command flags.Commander, args []string) error {
+		if err := opts.ConfigureClient(leto.DefaultRPCClient); err != nil {
+			return err
+		}
+		leto.DefaultNodeCacheTTL = opts.NodeCacheTTL
+		if command == nil {
+			return nil
+		}
+		return command.Execute(args)
 	}
 
-	_, err = parser.Parse()
+	_, err := parser.ParseArgs(args)
 	if ferr, ok := err.(*flags.Error); ok == true && ferr.Type == flags.ErrHelp {
 		err = nil
 	}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"time"
+
+	"github.com/formicidae-tracker/leto"
+	. "gopkg.in/check.v1"
+)
+
+type OptionsSuite struct{}
+
+var _ = Suite(&OptionsSuite{})
+
+func (s *OptionsSuite) TestNodeCacheTTLIsSetBeforeListingNodes(c *C) {
+	defer func(ttl time.Duration) {
+		leto.DefaultNodeCacheTTL = ttl
+		commandLineNodes = nil
+	}(leto.DefaultNodeCacheTTL)
+
+	c.Assert(execute([]string{"--node-cache-ttl", "1m", "--node", "foo.lan:4242", "version"}), IsNil)
+	c.Check(leto.DefaultNodeCacheTTL, Equals, time.Minute)
+	// version does not need nodes, they are not listed
+	c.Check(nodes, IsNil)
+	c.Assert(commandLineNodes, HasLen, 1)
+	c.Check(commandLineNodes[0].Port, Equals, 4242)
+}
@@ -10,7 +10,6 @@ import (
 	"time"
 
 	"github.com/formicidae-tracker/leto"
-	"gopkg.in/yaml.v2"
 )
 
 var REFRESH_DURATION = 1 * time.Minute
@@ -44,7 +43,9 @@ func (c *MonitorCommand) getStatuses() (map[string]*leto.TrackingConfiguration,
 			defer wg.Done()
 
 			status := leto.Status{}
-			err := n.RunMethod("Leto.Status", &leto.NoArgs{}, &status)
+			ctx, cancel := rpcContext()
+			defer cancel()
+			err := n.Call(ctx, "Leto.Status", &leto.NoArgs{}, &status)
 			if err != nil {
 				results <- Result{Instance: n.Name, Config: nil, Error: err}
 				return
@@ -55,15 +56,14 @@ func (c *MonitorCommand) getStatuses() (map[string]*leto.TrackingConfiguration,
 				return
 			}
 
-			config := leto.TrackingConfiguration{}
-			err = yaml.Unmarshal([]byte(status.Experiment.YamlConfiguration), &config)
+			config, err := leto.ParseConfiguration([]byte(status.Experiment.YamlConfiguration))
 
 			if err != nil {
 				results <- Result{Instance: n.Name, Config: nil, Error: err}
 				return
 			}
 
-			results <- Result{Instance: n.Name, Config: &config, Error: nil}
+			results <- Result{Instance: n.Name, Config: config, Error: nil}
 
 		}()
 	}
@@ -27,7 +27,9 @@ type networkState struct {
 
 func fetchNodeConfig(n leto.Node) (*leto.TrackingConfiguration, error) {
 	status := leto.Status{}
-	err := n.RunMethod("Leto.Status", &leto.NoArgs{}, &status)
+	ctx, cancel := rpcContext()
+	defer cancel()
+	err := n.Call(ctx, "Leto.Status", &leto.NoArgs{}, &status)
 	if err != nil {
 		return nil, err
 	}
@@ -35,16 +37,14 @@ func fetchNodeConfig(n leto.Node) (*leto.TrackingConfiguration, error) {
 	if status.Experiment == nil {
 		return nil, nil
 	}
-	config := leto.TrackingConfiguration{}
-
-	err = yaml.Unmarshal([]byte(status.Experiment.YamlConfiguration), &config)
+	config, err := leto.ParseConfiguration([]byte(status.Experiment.YamlConfiguration))
 	if err != nil {
 		return nil, err
 	}
 	// strips load balancing configuration
 	config.Loads = nil
 
-	return &config, nil
+	return config, nil
 }
 
 func fetchNetworkState() (*networkState, error) {
@@ -93,7 +93,9 @@ func stopTracking(name Nodename) error {
 		return err
 	}
 	reply := leto.Response{}
-	err = node.RunMethod("Leto.StopTracking", &leto.NoArgs{}, &reply)
+	ctx, cancel := rpcContext()
+	defer cancel()
+	err = node.Call(ctx, "Leto.StopTracking", &leto.NoArgs{}, &reply)
 	if err != nil {
 		return err
 	}
@@ -106,7 +108,9 @@ func startTracking(name Nodename, config leto.TrackingConfiguration) error {
 		return err
 	}
 	reply := leto.Response{}
-	err = node.RunMethod("Leto.StartTracking", &config, &reply)
+	ctx, cancel := rpcContext()
+	defer cancel()
+	err = node.Call(ctx, "Leto.StartTracking", &config, &reply)
 	if err != nil {
 		return err
 	}
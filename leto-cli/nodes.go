@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/atuleu/go-tablifier"
+	"github.com/formicidae-tracker/leto"
+)
+
+type NodesCommand struct {
+	Refresh bool `long:"refresh" description:"flushes the node cache and looks for nodes again on the network"`
+}
+
+var nodesCommand = &NodesCommand{}
+
+type NodeTableLine struct {
+	Node    string
+	Address string
+	Port    int
+	Source  string
+	Cached  string
+}
+
+func (c *NodesCommand) Execute(args []string) error {
+	lister := leto.NewNodeLister()
+	if c.Refresh == true {
+		if err := lister.Flush(); err != nil {
+			return fmt.Errorf("Could not flush node cache: %s", err)
+		}
+	}
+
+	listed, err := lister.ListNodes()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Cache: %s\n", lister.CacheFilePath())
+	if lister.CacheDate.IsZero() == true {
+		fmt.Printf("Updated: never\n")
+	} else {
+		fmt.Printf("Updated: %s ago\n", time.Since(lister.CacheDate).Round(time.Millisecond))
+	}
+	fmt.Printf("TTL: %s\n", lister.TTL)
+
+	lines := make([]NodeTableLine, 0, len(listed))
+	for _, n := range listed {
+		line := NodeTableLine{
+			Node:    n.Name,
+			Address: n.Address,
+			Port:    n.Port,
+			Source:  strings.Join(n.Sources, "+"),
+			Cached:  "no",
+		}
+		if _, ok := lister.Cache[n.Name]; ok == true {
+			line.Cached = "yes"
+		}
+		lines = append(lines, line)
+	}
+	sort.Slice(lines, func(i, j int) bool { return lines[i].Node < lines[j].Node })
+
+	tablifier.Tablify(lines)
+
+	return nil
+}
+
+func init() {
+	_, err := parser.AddCommand("nodes", "displays the node cache", "Displays the nodes known from the local node cache and the static inventory, and optionally refreshes the cache", nodesCommand)
+	if err != nil {
+		panic(err.Error())
+	}
+}
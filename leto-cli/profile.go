@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/formicidae-tracker/leto"
+	"github.com/jessevdk/go-flags"
+)
+
+type ProfileCommand struct {
+}
+
+type ProfileListCommand struct {
+	Args struct {
+		Node Nodename
+	} `positional-args:"yes" required:"yes"`
+}
+
+type ProfileShowCommand struct {
+	Args struct {
+		Node    Nodename
+		Profile string
+	} `positional-args:"yes" required:"yes"`
+}
+
+type ProfileSaveCommand struct {
+	Args struct {
+		Node       Nodename
+		Profile    string
+		ConfigFile flags.Filename
+	} `positional-args:"yes" required:"yes"`
+}
+
+type ProfileDeleteCommand struct {
+	Args struct {
+		Node    Nodename
+		Profile string
+	} `positional-args:"yes" required:"yes"`
+}
+
+func listProfiles(name Nodename) ([]leto.Profile, error) {
+	n, err := name.GetNode()
+	if err != nil {
+		return nil, err
+	}
+	reply := leto.ProfileList{}
+	ctx, cancel := rpcContext()
+	defer cancel()
+	if err := n.Call(ctx, "Leto.ListProfiles", &leto.NoArgs{}, &reply); err != nil {
+		return nil, err
+	}
+	return reply.Profiles, nil
+}
+
+func (c *ProfileListCommand) Execute(args []string) error {
+	profiles, err := listProfiles(c.Args.Node)
+	if err != nil {
+		return err
+	}
+	for _, p := range profiles {
+		fmt.Println(p.Name)
+	}
+	return nil
+}
+
+func (c *ProfileShowCommand) Execute(args []string) error {
+	profiles, err := listProfiles(c.Args.Node)
+	if err != nil {
+		return err
+	}
+	for _, p := range profiles {
+		if p.Name != c.Args.Profile {
+			continue
+		}
+		fmt.Printf("=== Profile '%s' YAML Configuration START ===\n", p.Name)
+		fmt.Println(p.YamlConfiguration)
+		fmt.Printf("=== Profile '%s' YAML Configuration END ===\n", p.Name)
+		return nil
+	}
+	return fmt.Errorf("Could not find profile '%s' on '%s'", c.Args.Profile, c.Args.Node)
+}
+
+func (c *ProfileSaveCommand) Execute(args []string) error {
+	n, err := c.Args.Node.GetNode()
+	if err != nil {
+		return err
+	}
+	config, err := leto.ReadConfiguration(string(c.Args.ConfigFile))
+	if err != nil {
+		return err
+	}
+	if err := config.Validate().ToError(); err != nil {
+		return fmt.Errorf("invalid tracking configuration: %s", err)
+	}
+	yamlConfig, err := config.Yaml()
+	if err != nil {
+		return err
+	}
+	resp := &leto.Response{}
+	ctx, cancel := rpcContext()
+	defer cancel()
+	err = n.Call(ctx, "Leto.SaveProfile", &leto.Profile{Name: c.Args.Profile, YamlConfiguration: string(yamlConfig)}, resp)
+	if err != nil {
+		return err
+	}
+	return resp.ToError()
+}
+
+func (c *ProfileDeleteCommand) Execute(args []string) error {
+	n, err := c.Args.Node.GetNode()
+	if err != nil {
+		return err
+	}
+	resp := &leto.Response{}
+	ctx, cancel := rpcContext()
+	defer cancel()
+	if err := n.Call(ctx, "Leto.DeleteProfile", &leto.Profile{Name: c.Args.Profile}, resp); err != nil {
+		return err
+	}
+	return resp.ToError()
+}
+
+func init() {
+	profileCommand, err := parser.AddCommand("profile", "manages configuration profiles on a node", "Lists, shows, saves and deletes named tracking configuration profiles stored on a node", &ProfileCommand{})
+	if err != nil {
+		panic(err.Error())
+	}
+	_, err = profileCommand.AddCommand("list", "lists profiles on a node", "Lists all profiles stored on a node", &ProfileListCommand{})
+	if err != nil {
+		panic(err.Error())
+	}
+	_, err = profileCommand.AddCommand("show", "shows a profile", "Shows the YAML configuration of a profile stored on a node", &ProfileShowCommand{})
+	if err != nil {
+		panic(err.Error())
+	}
+	_, err = profileCommand.AddCommand("save", "saves a profile", "Saves a configuration file as a named profile on a node, overwriting any existing profile", &ProfileSaveCommand{})
+	if err != nil {
+		panic(err.Error())
+	}
+	_, err = profileCommand.AddCommand("delete", "deletes a profile", "Deletes a named profile stored on a node", &ProfileDeleteCommand{})
+	if err != nil {
+		panic(err.Error())
+	}
+}
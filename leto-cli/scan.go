@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"log"
+	"os"
 	"sort"
 	"strings"
 	"sync"
@@ -10,7 +11,6 @@ import (
 
 	"github.com/atuleu/go-tablifier"
 	"github.com/formicidae-tracker/leto"
-	"gopkg.in/yaml.v2"
 )
 
 type ScanCommand struct {
@@ -18,101 +18,179 @@ type ScanCommand struct {
 
 var scanCommand = &ScanCommand{}
 
-type Result struct {
-	Instance string
-	Status   leto.Status
-}
-
-func (r Result) running() int {
-	if r.Status.Experiment == nil {
-		return 0
-	}
-	return 1
-}
-
 type ResultTableLine struct {
 	Node       string
+	Role       string
 	Status     string
 	Experiment string
 	Since      string
+	Version    string
 	Links      string
+	Source     string
 }
 
-func (c *ScanCommand) Execute(args []string) error {
+// completeFromStatus fills the information not advertised by older
+// leto instances with a Leto.Status request.
+func completeFromStatus(n leto.Node) (leto.Node, error) {
+	status := leto.Status{}
+	ctx, cancel := rpcContext()
+	defer cancel()
+	if err := n.Call(ctx, "Leto.Status", &leto.NoArgs{}, &status); err != nil {
+		return n, err
+	}
+	n.Master = status.Master
+	n.Misconfigured = len(status.SiteConfigurationError) > 0
+	switch {
+	case len(status.Master) > 0:
+		n.Role = leto.NODE_ROLE_SLAVE
+	case len(status.Slaves) > 0:
+		n.Role = leto.NODE_ROLE_MASTER
+	default:
+		n.Role = leto.NODE_ROLE_STANDALONE
+	}
+	if status.Experiment != nil {
+		n.Experiment = "N.A."
+		config, err := leto.ParseConfiguration([]byte(status.Experiment.YamlConfiguration))
+		if err == nil {
+			n.Experiment = config.ExperimentName
+		}
+		n.Since = status.Experiment.Since
+	}
+	return n, nil
+}
 
-	statuses := make(chan Result, 20)
-	errors := make(chan error, 20)
+func clusterName(n leto.Node) string {
+	if n.Role == leto.NODE_ROLE_SLAVE {
+		return n.Master
+	}
+	return n.Name
+}
+
+func (c *ScanCommand) Execute(args []string) error {
+	nodes := knownNodes()
+	results := make(chan leto.Node, len(nodes))
+	errors := make(chan error, len(nodes))
 	wg := sync.WaitGroup{}
 	for _, nlocal := range nodes {
 		n := nlocal
+		if n.Advertised() == true {
+			results <- n
+			continue
+		}
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-
-			status := leto.Status{}
-			err := n.RunMethod("Leto.Status", &leto.NoArgs{}, &status)
-
+			completed, err := completeFromStatus(n)
 			if err != nil {
 				errors <- err
 				return
 			}
-			statuses <- Result{Instance: n.Name, Status: status}
+			completed.Version = "unknown"
+			results <- completed
 		}()
 	}
 	go func() {
 		wg.Wait()
 		close(errors)
-		close(statuses)
+		close(results)
 	}()
 
 	for err := range errors {
 		log.Printf("Could not fetch status: %s", err)
 	}
 
-	lines := make([]ResultTableLine, 0, len(nodes))
+	scanned := make([]leto.Node, 0, len(nodes))
+	for n := range results {
+		scanned = append(scanned, n)
+	}
+
+	lines, skews, cliSkew := scanTable(scanned, time.Now())
+	tablifier.Tablify(lines)
+
+	if len(skews) > 0 {
+		fmt.Fprintf(os.Stderr, "\nVersion skew, slaves may refuse to track for their master:\n")
+		for _, s := range skews {
+			fmt.Fprintf(os.Stderr, " * %s\n", s)
+		}
+	}
+	if cliSkew > 0 {
+		fmt.Fprintf(os.Stderr, "\n%d node(s) do not run the same version than leto-cli (%s)\n", cliSkew, leto.LETO_VERSION)
+	}
+
+	return nil
+}
+
+// scanTable returns the lines displayed for the scanned nodes, sorted
+// by cluster, the version skews between masters and their slaves,
+// and the number of nodes not running the leto-cli version.
+func scanTable(scanned []leto.Node, now time.Time) ([]ResultTableLine, []string, int) {
+	slaves := make(map[string][]string)
+	for _, n := range scanned {
+		if n.Role == leto.NODE_ROLE_SLAVE {
+			slaves[n.Master] = append(slaves[n.Master], n.Name)
+		}
+	}
 
-	now := time.Now()
+	sort.Slice(scanned, func(i, j int) bool {
+		ci, cj := clusterName(scanned[i]), clusterName(scanned[j])
+		if ci != cj {
+			return ci < cj
+		}
+		// the master is displayed first in its cluster
+		if (scanned[i].Role == leto.NODE_ROLE_SLAVE) != (scanned[j].Role == leto.NODE_ROLE_SLAVE) {
+			return scanned[j].Role == leto.NODE_ROLE_SLAVE
+		}
+		return scanned[i].Name < scanned[j].Name
+	})
 
-	for r := range statuses {
+	versions := make(map[string]string)
+	for _, n := range scanned {
+		versions[n.Name] = n.Version
+	}
+
+	lines := make([]ResultTableLine, 0, len(scanned))
+	skews := []string{}
+	cliSkew := 0
+	for _, n := range scanned {
 		line := ResultTableLine{
-			Node:       strings.TrimPrefix(r.Instance, "leto."),
+			Node:       n.Name,
+			Role:       n.Role,
 			Status:     "Idle",
 			Experiment: "N.A.",
 			Since:      "N.A.",
+			Version:    n.Version,
+			Source:     strings.Join(n.Sources, "+"),
+		}
+		if n.Version != leto.LETO_VERSION {
+			cliSkew += 1
 		}
-		if len(r.Status.Master) != 0 {
-			line.Links = "↦ " + strings.TrimPrefix(r.Status.Master, "leto.")
-		} else if len(r.Status.Slaves) != 0 {
-			sep := "↤ "
-			for _, s := range r.Status.Slaves {
-				line.Links += sep + strings.TrimPrefix(s, "leto.")
-				sep = ",↤ "
+		if n.Role == leto.NODE_ROLE_SLAVE {
+			line.Node = "└ " + n.Name
+			line.Links = "↦ " + strings.TrimPrefix(n.Master, "leto.")
+			if masterVersion, ok := versions[n.Master]; ok == true && masterVersion != n.Version {
+				line.Version += " ⚠"
+				skews = append(skews, fmt.Sprintf("slave '%s' runs leto %s but its master '%s' runs %s", n.Name, n.Version, n.Master, masterVersion))
 			}
+		} else if s, ok := slaves[n.Name]; ok == true {
+			sort.Strings(s)
+			line.Links = "↤ " + strings.Join(s, ",↤ ")
+		}
+		if n.Misconfigured == true {
+			line.Status = "Misconfigured"
 		}
-		if r.Status.Experiment != nil {
+		if len(n.Experiment) > 0 {
 			line.Status = "Running"
-			config := leto.TrackingConfiguration{}
-			yaml.Unmarshal([]byte(r.Status.Experiment.YamlConfiguration), &config)
-			line.Experiment = config.ExperimentName
-			ellapsed := now.Sub(r.Status.Experiment.Since).Round(time.Second)
-			line.Since = fmt.Sprintf("%s", ellapsed)
+			line.Experiment = n.Experiment
+			if n.Since.IsZero() == false {
+				line.Since = fmt.Sprintf("%s", now.Sub(n.Since).Round(time.Second))
+			}
 		}
 		lines = append(lines, line)
 	}
-
-	sort.Slice(lines, func(i, j int) bool {
-		if lines[i].Status == lines[j].Status {
-			return lines[i].Node < lines[j].Node
-		}
-		return lines[i].Status == "Running"
-	})
-
-	tablifier.Tablify(lines)
-
-	return nil
+	return lines, skews, cliSkew
 }
 
 func init() {
-	parser.AddCommand("scan", "scans local network for leto instances", "Uses zeroconf to discover available leto instances and their status over the network", scanCommand)
+	parser.AddCommand("scan", "scans local network for leto instances", "Uses zeroconf and the static node inventory to find available leto instances and displays an overview of their clusters and experiments", scanCommand)
 
 }
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/formicidae-tracker/leto"
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type ScanSuite struct{}
+
+var _ = Suite(&ScanSuite{})
+
+func (s *ScanSuite) TestReportsMisconfiguredNodes(c *C) {
+	now := time.Now()
+	scanned := []leto.Node{
+		{Name: "foo", Version: leto.LETO_VERSION, Role: leto.NODE_ROLE_STANDALONE, Misconfigured: true},
+		{Name: "bar", Version: leto.LETO_VERSION, Role: leto.NODE_ROLE_STANDALONE},
+		{
+			Name:          "baz",
+			Version:       leto.LETO_VERSION,
+			Role:          leto.NODE_ROLE_STANDALONE,
+			Experiment:    "colony",
+			Since:         now.Add(-time.Minute),
+			Misconfigured: true,
+		},
+	}
+	lines, skews, cliSkew := scanTable(scanned, now)
+	c.Check(skews, HasLen, 0)
+	c.Check(cliSkew, Equals, 0)
+	c.Assert(lines, HasLen, 3)
+	status := map[string]string{}
+	for _, l := range lines {
+		status[l.Node] = l.Status
+	}
+	c.Check(status, DeepEquals, map[string]string{
+		"foo": "Misconfigured",
+		"bar": "Idle",
+		// a running experiment is still reported
+		"baz": "Running",
+	})
+}
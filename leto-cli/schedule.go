@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/formicidae-tracker/leto"
+)
+
+type ScheduleCommand struct {
+}
+
+type ScheduleListCommand struct {
+	Args struct {
+		Node Nodename
+	} `positional-args:"yes" required:"yes"`
+}
+
+type ScheduleAddCommand struct {
+	StartCommand
+}
+
+type ScheduleCancelCommand struct {
+	Args struct {
+		Node Nodename
+		ID   string
+	} `positional-args:"yes" required:"yes"`
+}
+
+var timeLayouts = []string{
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+}
+
+// parseTime parses a local time, or an RFC3339 time. An empty string
+// is the zero time.
+func parseTime(s string) (time.Time, error) {
+	if len(s) == 0 {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time '%s', expected 'YYYY-MM-DD HH:MM' or RFC3339", s)
+}
+
+func printSchedule(sc leto.Schedule) {
+	state := "pending"
+	if sc.Started == true {
+		state = "running"
+	}
+	action := fmt.Sprintf("experiment '%s'", sc.ExperimentName)
+	if sc.StopOnly == true {
+		action = fmt.Sprintf("stop of experiment '%s'", sc.ExperimentName)
+	} else if len(sc.Profile) > 0 {
+		action += fmt.Sprintf(" (profile '%s')", sc.Profile)
+	}
+	end := "until stopped"
+	if sc.End.IsZero() == false {
+		end = "to " + sc.End.Local().Format(time.RFC3339)
+	}
+	fmt.Printf("%s %-7s %s from %s %s\n", sc.ID, state, action, sc.Start.Local().Format(time.RFC3339), end)
+}
+
+func (c *ScheduleListCommand) Execute(args []string) error {
+	n, err := c.Args.Node.GetNode()
+	if err != nil {
+		return err
+	}
+	reply := leto.ScheduleList{}
+	ctx, cancel := rpcContext()
+	defer cancel()
+	if err := n.Call(ctx, "Leto.ListSchedules", &leto.NoArgs{}, &reply); err != nil {
+		return err
+	}
+	for _, sc := range reply.Schedules {
+		printSchedule(sc)
+	}
+	return nil
+}
+
+func (c *ScheduleAddCommand) Execute(args []string) error {
+	if c.isScheduled() == false {
+		return fmt.Errorf("at least one of --start-at, --stop-at or --run-for is required")
+	}
+	return c.StartCommand.Execute(args)
+}
+
+func (c *ScheduleCancelCommand) Execute(args []string) error {
+	n, err := c.Args.Node.GetNode()
+	if err != nil {
+		return err
+	}
+	resp := &leto.Response{}
+	ctx, cancel := rpcContext()
+	defer cancel()
+	if err := n.Call(ctx, "Leto.CancelSchedule", &leto.Schedule{ID: c.Args.ID}, resp); err != nil {
+		return err
+	}
+	return resp.ToError()
+}
+
+func init() {
+	scheduleCommand, err := parser.AddCommand("schedule", "manages scheduled experiments on a node", "Lists, adds and cancels experiments planned on a node. Schedules are kept by the node across restarts.", &ScheduleCommand{})
+	if err != nil {
+		panic(err.Error())
+	}
+	_, err = scheduleCommand.AddCommand("list", "lists schedules on a node", "Lists the pending and running scheduled experiments of a node", &ScheduleListCommand{})
+	if err != nil {
+		panic(err.Error())
+	}
+	_, err = scheduleCommand.AddCommand("add", "schedules an experiment", "Schedules an experiment, with the same arguments than start", &ScheduleAddCommand{})
+	if err != nil {
+		panic(err.Error())
+	}
+	_, err = scheduleCommand.AddCommand("cancel", "cancels a schedule", "Cancels a schedule. An experiment it started is not stopped.", &ScheduleCancelCommand{})
+	if err != nil {
+		panic(err.Error())
+	}
+}
@@ -2,13 +2,18 @@ package main
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/formicidae-tracker/leto"
 	"github.com/jessevdk/go-flags"
 )
 
 type StartCommand struct {
-	Config leto.TrackingConfiguration
+	Profile string        `long:"profile" description:"named profile stored on the node to use as base configuration"`
+	StartAt string        `long:"start-at" description:"schedules the start of the experiment at a local time like '2021-03-04 22:00', or an RFC3339 time"`
+	StopAt  string        `long:"stop-at" description:"schedules the stop of the experiment at a local time like '2021-03-07 22:00', or an RFC3339 time"`
+	RunFor  time.Duration `long:"run-for" description:"schedules the stop of the experiment after this duration, like 72h"`
+	Config  leto.TrackingConfiguration
 
 	Args struct {
 		Node       Nodename
@@ -18,6 +23,36 @@ type StartCommand struct {
 
 var startCommand = &StartCommand{}
 
+func (c *StartCommand) isScheduled() bool {
+	return len(c.StartAt) > 0 || len(c.StopAt) > 0 || c.RunFor != 0
+}
+
+func (c *StartCommand) schedule(n *leto.Node, config *leto.TrackingConfiguration) error {
+	yamlConfig, err := config.Yaml()
+	if err != nil {
+		return err
+	}
+	sc := &leto.Schedule{
+		Profile:           c.Profile,
+		YamlConfiguration: string(yamlConfig),
+		Duration:          c.RunFor,
+	}
+	if sc.Start, err = parseTime(c.StartAt); err != nil {
+		return err
+	}
+	if sc.End, err = parseTime(c.StopAt); err != nil {
+		return err
+	}
+	reply := leto.Schedule{}
+	ctx, cancel := rpcContext()
+	defer cancel()
+	if err := n.Call(ctx, "Leto.AddSchedule", sc, &reply); err != nil {
+		return err
+	}
+	printSchedule(reply)
+	return nil
+}
+
 func (c *StartCommand) Execute(args []string) error {
 	n, err := c.Args.Node.GetNode()
 	if err != nil {
@@ -33,11 +68,34 @@ func (c *StartCommand) Execute(args []string) error {
 		if err := fileConfig.Merge(config); err != nil {
 			return fmt.Errorf("Could not merge file and commandline configuration: %s", err)
 		}
+		// the node resets the fields to its own defaults
+		fileConfig.Reset = append(fileConfig.Reset, config.Reset...)
 		config = fileConfig
 	}
 	config.Loads = nil
+	if err := config.Validate().ToError(); err != nil {
+		return fmt.Errorf("invalid tracking configuration: %s", err)
+	}
+	if c.isScheduled() == true {
+		return c.schedule(n, config)
+	}
 	resp := &leto.Response{}
-	if err := n.RunMethod("Leto.StartTracking", config, resp); err != nil {
+	if len(c.Profile) > 0 {
+		overrides, err := config.Yaml()
+		if err != nil {
+			return err
+		}
+		args := &leto.ProfileTrackingStart{Profile: c.Profile, YamlOverrides: string(overrides)}
+		ctx, cancel := rpcContext()
+		defer cancel()
+		if err := n.Call(ctx, "Leto.StartTrackingProfile", args, resp); err != nil {
+			return err
+		}
+		return resp.ToError()
+	}
+	ctx, cancel := rpcContext()
+	defer cancel()
+	if err := n.Call(ctx, "Leto.StartTracking", config, resp); err != nil {
 		return err
 	}
 	return resp.ToError()
@@ -2,9 +2,9 @@ package main
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/formicidae-tracker/leto"
-	"gopkg.in/yaml.v2"
 )
 
 type StatusCommand struct {
@@ -22,7 +22,9 @@ func (c *StatusCommand) Execute(args []string) error {
 	}
 
 	status := leto.Status{}
-	if err := n.RunMethod("Leto.Status", &leto.NoArgs{}, &status); err != nil {
+	ctx, cancel := rpcContext()
+	defer cancel()
+	if err := n.Call(ctx, "Leto.Status", &leto.NoArgs{}, &status); err != nil {
 		return err
 	}
 
@@ -33,24 +35,66 @@ func (c *StatusCommand) Execute(args []string) error {
 		fmt.Printf("Type: Slave\nMaster : %s\n", status.Master)
 	}
 
+	if len(status.SiteConfigurationError) > 0 {
+		fmt.Printf("Site Configuration Error: %s\n", status.SiteConfigurationError)
+	}
+
+	if status.State == leto.EXPERIMENT_FAILED {
+		fmt.Printf("State: Failed: %s\n", status.Failure)
+		return nil
+	}
 	if status.Experiment == nil {
-		fmt.Printf("State: Idle\n")
+		if status.State == leto.EXPERIMENT_STARTING {
+			fmt.Printf("State: Starting\n")
+		} else {
+			fmt.Printf("State: Idle\n")
+		}
 		return nil
 	}
-	config := leto.TrackingConfiguration{}
-	err = yaml.Unmarshal([]byte(status.Experiment.YamlConfiguration), &config)
+	config, err := leto.ParseConfiguration([]byte(status.Experiment.YamlConfiguration))
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("State: Running Experiment '%s' since %s\n", config.ExperimentName, status.Experiment.Since)
+	state := "Running"
+	if status.State == leto.EXPERIMENT_STOPPING {
+		state = "Stopping"
+	}
+	fmt.Printf("State: %s Experiment '%s' since %s\n", state, config.ExperimentName, status.Experiment.Since)
 	fmt.Printf("Experiment Local Output Directory: %s\n", status.Experiment.ExperimentDir)
+	if status.Experiment.DroppedFrames > 0 {
+		fmt.Printf("Frames Missing From Tracking Files: %d (see %s)\n", status.Experiment.DroppedFrames, leto.FRAME_GAPS_FILE)
+		for _, g := range status.Experiment.FrameGaps {
+			fmt.Printf(" * frames %d to %d (%d) at %s: %s\n", g.First, g.Last, g.Frames(), g.Time.Format(time.RFC3339), g.Reason)
+		}
+	}
+	if d := status.Experiment.Disk; d != nil {
+		fmt.Printf("Free Disk Space: %.1f GiB (%s)\n", float64(d.Free)/(1024*1024*1024), d.Level)
+		if d.VideoSavingStopped == true {
+			fmt.Printf("Video Saving: stopped to keep space for tracking data\n")
+		}
+	}
+	printCrashes(status.Experiment.Crashes)
 	fmt.Printf("=== Experiment YAML Configuration START ===\n")
 	fmt.Println(status.Experiment.YamlConfiguration)
 	fmt.Printf("=== Experiment YAML Configuration END ===\n")
 	return nil
 }
 
+func printCrashes(crashes []leto.ArtemisCrash) {
+	if len(crashes) == 0 {
+		return
+	}
+	fmt.Printf("Artemis Crashes: %d\n", len(crashes))
+	for _, c := range crashes {
+		action := "not restarted"
+		if c.Restarted == true {
+			action = "restarted"
+		}
+		fmt.Printf(" * %s exit code %d (%s), %s\n", c.Time.Format(time.RFC3339), c.ExitCode, c.Error, action)
+	}
+}
+
 func init() {
 	_, err := parser.AddCommand("status", "queries the full status on a speciied node", "Queries the complete status on a specified node", statusCommand)
 	if err != nil {
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"time"
+
 	"github.com/formicidae-tracker/leto"
 )
 
 type StopCommand struct {
+	At   string        `long:"at" description:"schedules the stop at a local time like '2021-03-07 22:00', or an RFC3339 time"`
+	In   time.Duration `long:"in" description:"schedules the stop after this duration, like 72h"`
 	Args struct {
 		Node Nodename
 	} `positional-args:"yes" required:"yes"`
@@ -18,8 +22,25 @@ func (c *StopCommand) Execute([]string) error {
 		return err
 	}
 
+	if len(c.At) > 0 || c.In != 0 {
+		sc := &leto.Schedule{StopOnly: true, Duration: c.In}
+		if sc.End, err = parseTime(c.At); err != nil {
+			return err
+		}
+		reply := leto.Schedule{}
+		ctx, cancel := rpcContext()
+		defer cancel()
+		if err := n.Call(ctx, "Leto.AddSchedule", sc, &reply); err != nil {
+			return err
+		}
+		printSchedule(reply)
+		return nil
+	}
+
 	resp := &leto.Response{}
-	if err := n.RunMethod("Leto.StopTracking", &leto.NoArgs{}, resp); err != nil {
+	ctx, cancel := rpcContext()
+	defer cancel()
+	if err := n.Call(ctx, "Leto.StopTracking", &leto.NoArgs{}, resp); err != nil {
 		return err
 	}
 	return resp.ToError()
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/formicidae-tracker/leto"
+	"github.com/jessevdk/go-flags"
+)
+
+type UpdateCommand struct {
+	Config leto.TrackingConfiguration
+
+	Args struct {
+		Node       Nodename
+		ConfigFile flags.Filename
+	} `positional-args:"yes"`
+}
+
+var updateCommand = &UpdateCommand{}
+
+func (c *UpdateCommand) Execute(args []string) error {
+	n, err := c.Args.Node.GetNode()
+	if err != nil {
+		return err
+	}
+
+	config := &(c.Config)
+	if len(c.Args.ConfigFile) > 0 {
+		fileConfig, err := leto.ReadConfiguration(string(c.Args.ConfigFile))
+		if err != nil {
+			return err
+		}
+		if err := fileConfig.Merge(config); err != nil {
+			return fmt.Errorf("Could not merge file and commandline configuration: %s", err)
+		}
+		// the node resets the fields to its own defaults
+		fileConfig.Reset = append(fileConfig.Reset, config.Reset...)
+		config = fileConfig
+	}
+	config.Loads = nil
+
+	ctx, cancel := rpcContext()
+	defer cancel()
+	if err := n.CheckCompatible(ctx, leto.FEATURE_UPDATE_TRACKING); err != nil {
+		return err
+	}
+	resp := &leto.Response{}
+	if err := n.Call(ctx, "Leto.UpdateTracking", config, resp); err != nil {
+		return err
+	}
+	return resp.ToError()
+}
+
+func init() {
+	_, err := parser.AddCommand("update", "updates the running experiment of a node", "Changes the highlights or the stream settings of the running experiment of a node, without starting a new experiment. Other changes are refused.", updateCommand)
+	if err != nil {
+		panic(err.Error())
+	}
+}
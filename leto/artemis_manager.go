@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -14,23 +16,25 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/adrg/xdg"
 	"github.com/blang/semver"
 	"github.com/formicidae-tracker/hermes"
 	"github.com/formicidae-tracker/leto"
 	"github.com/google/uuid"
-	"gopkg.in/yaml.v2"
 )
 
 type ArtemisManager struct {
 	incoming, merged, file, broadcast chan *hermes.FrameReadout
+	mergeReset                        chan struct{}
 	mx                                sync.Mutex
 	wg, artemisWg, trackerWg          sync.WaitGroup
 	fileWriter                        *FrameReadoutFileWriter
+	frameGaps                         *FrameGapRecorder
 	trackers                          *RemoteManager
 	nodeConfig                        NodeConfiguration
+	options                           Options
 
 	artemisCmd    *exec.Cmd
 	artemisOut    *io.PipeWriter
@@ -46,10 +50,38 @@ type ArtemisManager struct {
 	since            time.Time
 
 	lastExperimentLog *leto.ExperimentLog
+
+	// state is the experiment state. While it is
+	// leto.EXPERIMENT_STARTING or leto.EXPERIMENT_STOPPING, the
+	// experiment fields are owned by the goroutine performing the
+	// transition, which may not hold mx.
+	state   leto.ExperimentState
+	failure error
+
+	stateChanged chan struct{}
+
+	events        *EventBroker
+	diskWatchQuit chan struct{}
+	disk          *leto.DiskStatus
+	// running is set while the local artemis is running and not
+	// being stopped. It is accessed atomically.
+	running int32
+
+	// stopTracker is closed by Stop to interrupt a pending restart.
+	stopTracker chan struct{}
+	restarts    []time.Time
+	crashes     []leto.ArtemisCrash
+	// reloadTracker is set when artemis is stopped to apply a new
+	// configuration.
+	reloadTracker bool
+	// revision counts the configuration updates of the experiment.
+	revision int
+
+	siteConfig siteConfigurationCheck
 }
 
-func NewArtemisManager() (*ArtemisManager, error) {
-	cmd := exec.Command("artemis", "--version")
+func NewArtemisManager(options Options) (*ArtemisManager, error) {
+	cmd := exec.Command(options.ArtemisPath, "--version")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return nil, fmt.Errorf("Could not find artemis: %s", err)
@@ -61,13 +93,13 @@ func NewArtemisManager() (*ArtemisManager, error) {
 		return nil, err
 	}
 
-	cmd = exec.Command("ffmpeg", "-version")
+	cmd = exec.Command(options.FFMpegPath, "-version")
 	_, err = cmd.CombinedOutput()
 	if err != nil {
 		return nil, fmt.Errorf("Could not find ffmpeg: %s", err)
 	}
 
-	nodeConfig := GetNodeConfiguration()
+	nodeConfig := GetNodeConfiguration(options.NodeConfigPath)
 
 	err = getAndCheckFirmwareVariant(nodeConfig, false)
 	if err != nil {
@@ -75,34 +107,111 @@ func NewArtemisManager() (*ArtemisManager, error) {
 	}
 
 	return &ArtemisManager{
-		nodeConfig: nodeConfig,
-		logger:     log.New(os.Stderr, "[artemis] ", 0),
+		nodeConfig:   nodeConfig,
+		options:      options,
+		logger:       newLogger("[artemis] "),
+		stateChanged: make(chan struct{}, 1),
+		events:       NewEventBroker(options.Name, EVENT_HISTORY_SIZE),
+		state:        leto.EXPERIMENT_IDLE,
 	}, nil
 }
 
 func (m *ArtemisManager) Status() leto.Status {
+	// the site configuration is not part of the experiment state.
+	siteErr := m.siteConfig.Error(m.options.SiteConfigPath)
+
 	m.mx.Lock()
 	defer m.mx.Unlock()
 	res := leto.Status{
 		Master:     m.nodeConfig.Master,
 		Slaves:     m.nodeConfig.Slaves,
 		Experiment: nil,
+		State:      m.state,
+	}
+	if m.failure != nil {
+		res.Failure = m.failure.Error()
 	}
 
-	yamlConfig, err := m.experimentConfig.Yaml()
-	if err != nil {
-		yamlConfig = []byte(fmt.Sprintf("Could not generate yaml config: %s", err))
+	if siteErr != nil {
+		res.SiteConfigurationError = siteErr.Error()
 	}
-	if m.incoming != nil {
+
+	if m.isStarted() == true {
+		yamlConfig, err := m.experimentConfig.Yaml()
+		if err != nil {
+			yamlConfig = []byte(fmt.Sprintf("Could not generate yaml config: %s", err))
+		}
 		res.Experiment = &leto.ExperimentStatus{
 			ExperimentDir:     filepath.Base(m.experimentDir),
 			YamlConfiguration: string(yamlConfig),
 			Since:             m.since,
 		}
+		if m.frameGaps != nil {
+			res.Experiment.DroppedFrames, res.Experiment.FrameGaps = m.frameGaps.Status()
+		}
+		if m.disk != nil {
+			disk := *m.disk
+			res.Experiment.Disk = &disk
+		}
+		res.Experiment.Crashes = append([]leto.ArtemisCrash(nil), m.crashes...)
+	}
+	return res
+}
+
+// AdvertisedNode returns the node description advertised over
+// zeroconf for the current state.
+func (m *ArtemisManager) AdvertisedNode() leto.Node {
+	misconfigured := m.siteConfig.Error(m.options.SiteConfigPath) != nil
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	res := m.options.Node()
+	res.Version = leto.LETO_VERSION
+	res.Misconfigured = misconfigured
+	res.Master = m.nodeConfig.Master
+	switch {
+	case len(m.nodeConfig.Master) > 0:
+		res.Role = leto.NODE_ROLE_SLAVE
+	case len(m.nodeConfig.Slaves) > 0:
+		res.Role = leto.NODE_ROLE_MASTER
+	default:
+		res.Role = leto.NODE_ROLE_STANDALONE
+	}
+	if m.isStarted() == true {
+		res.Experiment = m.experimentConfig.ExperimentName
+		res.Since = m.since
 	}
 	return res
 }
 
+// RunningExperiment returns the name of the running experiment, if
+// any.
+func (m *ArtemisManager) RunningExperiment() (string, bool) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	if m.state != leto.EXPERIMENT_RUNNING {
+		return "", false
+	}
+	return m.experimentConfig.ExperimentName, true
+}
+
+// StateChanged is notified each time the advertised node description
+// may have changed.
+func (m *ArtemisManager) StateChanged() <-chan struct{} {
+	return m.stateChanged
+}
+
+func (m *ArtemisManager) notifyStateChange() {
+	select {
+	case m.stateChanged <- struct{}{}:
+	default:
+	}
+}
+
+// Events returns the EventBroker publishing the node events.
+func (m *ArtemisManager) Events() *EventBroker {
+	return m.events
+}
+
 func (m *ArtemisManager) LastExperimentLog() *leto.ExperimentLog {
 	m.mx.Lock()
 	defer m.mx.Unlock()
@@ -110,58 +219,99 @@ func (m *ArtemisManager) LastExperimentLog() *leto.ExperimentLog {
 }
 
 func (m *ArtemisManager) Start(userConfig *leto.TrackingConfiguration) error {
+	return m.StartFor(userConfig, 0)
+}
+
+// StartFor starts an experiment planned to run for d, or until it is
+// stopped if d is zero. The planned duration is used to check the
+// free space.
+func (m *ArtemisManager) StartFor(userConfig *leto.TrackingConfiguration, d time.Duration) error {
 	m.mx.Lock()
-	defer m.mx.Unlock()
-	if m.incoming != nil {
-		return fmt.Errorf("ArtemisManager: Start: already started")
+	if err := m.requireState("start an experiment", leto.EXPERIMENT_IDLE, leto.EXPERIMENT_FAILED); err != nil {
+		m.mx.Unlock()
+		return err
 	}
+	m.setState(leto.EXPERIMENT_STARTING, nil)
+	m.mx.Unlock()
 
-	if err := m.setUpExperiment(userConfig); err != nil {
+	// setting up may take a while, the state machine ensures nothing
+	// else touches the experiment meanwhile.
+	err := m.setUpExperiment(userConfig, d)
+
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	if err != nil {
+		m.cleanUpGlobalVariables()
+		m.setState(leto.EXPERIMENT_FAILED, err)
 		return err
 	}
 
+	metrics.ResetProducers()
+
 	m.spawnTasks()
 
-	m.registerOlympus()
+	m.registerOlympus(m.experimentConfig)
 
 	m.writePersistentFile()
 
+	m.events.SetExperiment(m.experimentConfig.ExperimentName)
+	m.events.Publish(leto.Event{
+		Type: leto.EVENT_EXPERIMENT_STARTED,
+		Path: filepath.Base(m.experimentDir),
+	})
+
+	m.setState(leto.EXPERIMENT_RUNNING, nil)
+
 	return nil
 }
 
+// Stop stops the experiment and waits for it to be torn down.
 func (m *ArtemisManager) Stop() error {
+	if err := m.requestStop(); err != nil {
+		return err
+	}
+	m.artemisWg.Wait()
+	return nil
+}
+
+func (m *ArtemisManager) requestStop() error {
 	m.mx.Lock()
 	defer m.mx.Unlock()
 
-	if m.isStarted() == false {
-		return fmt.Errorf("Already stoppped")
+	if err := m.requireState("stop the experiment", leto.EXPERIMENT_RUNNING); err != nil {
+		return err
+	}
+	m.setState(leto.EXPERIMENT_STOPPING, nil)
+
+	atomic.StoreInt32(&m.running, 0)
+	if m.stopTracker != nil {
+		close(m.stopTracker)
+		m.stopTracker = nil
 	}
 
 	m.removePersistentFile()
 
-	m.unregisterOlympus()
+	m.unregisterOlympus(m.experimentConfig)
 
 	if m.artemisCmd != nil {
 		if m.nodeConfig.IsMaster() == true {
 			m.stopSlavesTrackers()
 		}
 
-		m.artemisCmd.Process.Signal(os.Interrupt)
+		// Process is nil if artemis failed to restart
+		if m.artemisCmd.Process != nil {
+			m.artemisCmd.Process.Signal(os.Interrupt)
+		}
 		m.logger.Printf("Waiting for artemis process to stop")
-		m.artemisCmd = nil
 	}
-
-	m.mx.Unlock()
-	m.artemisWg.Wait()
-	m.mx.Lock()
 	return nil
 }
 
 func (m *ArtemisManager) SetMaster(hostname string) error {
 	m.mx.Lock()
 	defer m.mx.Unlock()
-	if m.isStarted() == true {
-		return fmt.Errorf("Could not change master/slave configuration while experiment %s is running", m.experimentConfig.ExperimentName)
+	if err := m.requireState("change master/slave configuration", leto.EXPERIMENT_IDLE, leto.EXPERIMENT_FAILED); err != nil {
+		return err
 	}
 	return m.setMaster(hostname)
 }
@@ -169,7 +319,8 @@ func (m *ArtemisManager) SetMaster(hostname string) error {
 func (m *ArtemisManager) setMaster(hostname string) (err error) {
 	defer func() {
 		if err == nil {
-			m.nodeConfig.Save()
+			m.nodeConfig.Save(m.options.NodeConfigPath)
+			m.notifyStateChange()
 		}
 	}()
 
@@ -179,7 +330,7 @@ func (m *ArtemisManager) setMaster(hostname string) (err error) {
 	}
 
 	if len(m.nodeConfig.Slaves) != 0 {
-		err = fmt.Errorf("Cannot set node as slave as it has its own slaves (%s)", m.nodeConfig.Slaves)
+		err = &refusedError{fmt.Errorf("Cannot set node as slave as it has its own slaves (%s)", m.nodeConfig.Slaves)}
 		return
 	}
 	m.nodeConfig.Master = hostname
@@ -193,8 +344,8 @@ func (m *ArtemisManager) setMaster(hostname string) (err error) {
 func (m *ArtemisManager) AddSlave(hostname string) (err error) {
 	m.mx.Lock()
 	defer m.mx.Unlock()
-	if m.isStarted() == true {
-		return fmt.Errorf("Could not change master/slave configuration while experiment %s is running", m.experimentConfig.ExperimentName)
+	if err := m.requireState("change master/slave configuration", leto.EXPERIMENT_IDLE, leto.EXPERIMENT_FAILED); err != nil {
+		return err
 	}
 
 	return m.addSlave(hostname)
@@ -203,7 +354,8 @@ func (m *ArtemisManager) AddSlave(hostname string) (err error) {
 func (m *ArtemisManager) addSlave(hostname string) (err error) {
 	defer func() {
 		if err == nil {
-			m.nodeConfig.Save()
+			m.nodeConfig.Save(m.options.NodeConfigPath)
+			m.notifyStateChange()
 		}
 	}()
 
@@ -216,15 +368,17 @@ func (m *ArtemisManager) addSlave(hostname string) (err error) {
 		return
 	}
 
-	err = m.nodeConfig.AddSlave(hostname)
+	if err = m.nodeConfig.AddSlave(hostname); err != nil {
+		err = &refusedError{err}
+	}
 	return
 }
 
 func (m *ArtemisManager) RemoveSlave(hostname string) (err error) {
 	m.mx.Lock()
 	defer m.mx.Unlock()
-	if m.isStarted() == true {
-		return fmt.Errorf("Could not change master/slave configuration while experiment %s is running", m.experimentConfig.ExperimentName)
+	if err := m.requireState("change master/slave configuration", leto.EXPERIMENT_IDLE, leto.EXPERIMENT_FAILED); err != nil {
+		return err
 	}
 	return m.removeSlave(hostname)
 }
@@ -232,11 +386,15 @@ func (m *ArtemisManager) RemoveSlave(hostname string) (err error) {
 func (m *ArtemisManager) removeSlave(hostname string) (err error) {
 	defer func() {
 		if err == nil {
-			m.nodeConfig.Save()
+			m.nodeConfig.Save(m.options.NodeConfigPath)
+			m.notifyStateChange()
 		}
 	}()
 
-	return m.nodeConfig.RemoveSlave(hostname)
+	if err = m.nodeConfig.RemoveSlave(hostname); err != nil {
+		err = &refusedError{err}
+	}
+	return
 }
 
 func checkArtemisVersion(actual, minimal string) error {
@@ -309,21 +467,32 @@ func extractCoaxlinkFirmwareOutput(output []byte) (string, error) {
 	return m[1], nil
 }
 
+// isStarted returns true if the experiment fields can be read. m.mx
+// must be held.
 func (m *ArtemisManager) isStarted() bool {
-	return m.incoming != nil
+	return m.state == leto.EXPERIMENT_RUNNING || m.state == leto.EXPERIMENT_STOPPING
 }
 
-func (m *ArtemisManager) setUpExperiment(userConfig *leto.TrackingConfiguration) error {
+func (m *ArtemisManager) setUpExperiment(userConfig *leto.TrackingConfiguration, planned time.Duration) error {
 	if err := m.mergeConfiguration(userConfig); err != nil {
 		return err
 	}
 
 	m.setUpTestMode()
 
+	if err := m.checkSlavesCompatibility(); err != nil {
+		return err
+	}
+
 	if err := m.setUpExperimentDir(); err != nil {
 		return err
 	}
 
+	if err := m.checkExperimentDiskSpace(planned); err != nil {
+		os.Remove(m.experimentDir)
+		return err
+	}
+
 	if err := m.setUpTrackerTask(); err != nil {
 		return err
 	}
@@ -334,12 +503,10 @@ func (m *ArtemisManager) setUpExperiment(userConfig *leto.TrackingConfiguration)
 		}
 	}
 
-	if err := m.backUpConfigToExperimentDir(); err != nil {
+	if err := m.backUpConfigToExperimentDir(nil); err != nil {
 		return err
 	}
 
-	// we sets the channel last, as it sets the experiment as started
-	// externally, an we do it only were there were no error.
 	m.incoming = make(chan *hermes.FrameReadout, 10)
 
 	return nil
@@ -349,12 +516,13 @@ func (m *ArtemisManager) spawnTasks() {
 	if m.nodeConfig.IsMaster() == true {
 		m.spawnMasterSubTasks()
 	}
+	m.spawnDiskWatchTask()
 	m.spawnLocalTracker()
 }
 
 func (m *ArtemisManager) getExperimentDirName(expname string) (string, error) {
 	if m.testMode == false {
-		basename := filepath.Join(xdg.DataHome, "fort-experiments", expname)
+		basename := filepath.Join(m.options.ExperimentsDir(), expname)
 		basedir, _, err := FilenameWithoutOverwrite(basename)
 		return basedir, err
 	}
@@ -408,7 +576,7 @@ func (m *ArtemisManager) setUpLoadBalancing() error {
 	if m.nodeConfig.IsMaster() {
 		m.experimentConfig.Loads = generateLoadBalancing(m.nodeConfig)
 		if len(m.nodeConfig.Slaves) > 0 {
-			cmd := exec.Command("artemis", "--fetch-resolution")
+			cmd := exec.Command(m.options.ArtemisPath, "--fetch-resolution")
 
 			if m.experimentConfig.Camera.StubPaths != nil || len(*m.experimentConfig.Camera.StubPaths) > 0 {
 				cmd.Args = append(cmd.Args, "--stub-image-paths", strings.Join(*m.experimentConfig.Camera.StubPaths, ","))
@@ -429,10 +597,13 @@ func (m *ArtemisManager) setUpLoadBalancing() error {
 }
 
 func (m *ArtemisManager) mergeConfiguration(userConfig *leto.TrackingConfiguration) error {
-	config := leto.LoadDefaultConfig()
+	config, _, err := leto.LoadDefaultConfigFile(m.options.SiteConfigPath)
+	if err != nil {
+		return &refusedError{fmt.Errorf("refusing to start: %s", err)}
+	}
 
 	if err := config.Merge(userConfig); err != nil {
-		return fmt.Errorf("could not merge user configuration: %s", err)
+		return &configurationError{fmt.Errorf("could not merge user configuration: %s", err)}
 	}
 
 	m.experimentConfig = config
@@ -442,7 +613,11 @@ func (m *ArtemisManager) mergeConfiguration(userConfig *leto.TrackingConfigurati
 	}
 
 	if err := m.experimentConfig.CheckAllFieldAreSet(); err != nil {
-		return fmt.Errorf("incomplete tracking configuration: %s", err)
+		return &configurationError{fmt.Errorf("incomplete tracking configuration: %s", err)}
+	}
+
+	if err := m.experimentConfig.Validate().ToError(); err != nil {
+		return &configurationError{fmt.Errorf("invalid tracking configuration: %s", err)}
 	}
 
 	m.workBalance = buildWorkloadBalance(config.Loads, *config.Camera.FPS)
@@ -454,11 +629,13 @@ func (m *ArtemisManager) setUpSubTasksChannels() {
 	m.merged = make(chan *hermes.FrameReadout, 10)
 	m.file = make(chan *hermes.FrameReadout, 200)
 	m.broadcast = make(chan *hermes.FrameReadout, 10)
+	m.mergeReset = make(chan struct{}, 1)
 }
 
 func (m *ArtemisManager) setUpFileWriterTask() error {
 	var err error
-	m.fileWriter, err = NewFrameReadoutWriter(filepath.Join(m.experimentDir, "tracking.hermes"))
+	m.frameGaps = NewFrameGapRecorder(filepath.Join(m.experimentDir, leto.FRAME_GAPS_FILE))
+	m.fileWriter, err = NewFrameReadoutWriter(filepath.Join(m.experimentDir, "tracking.hermes"), m.events, m.frameGaps)
 	return err
 }
 
@@ -466,8 +643,15 @@ func (m *ArtemisManager) setUpStreamTask() error {
 	var err error
 	m.streamIn, m.artemisOut = io.Pipe()
 	m.artemisCmd.Stdout = m.artemisOut
-	m.streamManager, err = NewStreamManager(m.experimentDir, *m.experimentConfig.Camera.FPS/float64(m.workBalance.Stride), m.experimentConfig.Stream)
-	return err
+	m.streamManager, err = NewStreamManager(m.options.FFMpegPath, m.experimentDir, *m.experimentConfig.Camera.FPS/float64(m.workBalance.Stride), m.experimentConfig.Stream, m.events)
+	if err != nil {
+		return err
+	}
+	// a relaunched stream must not save video again on a full disk
+	if m.disk != nil && m.disk.VideoSavingStopped == true {
+		m.streamManager.StopSaving()
+	}
+	return nil
 }
 
 func (m *ArtemisManager) antOutputDir() string {
@@ -520,22 +704,31 @@ func (m *ArtemisManager) setUpExperimentDir() error {
 	return os.MkdirAll(m.experimentDir, 0755)
 }
 
-func (m *ArtemisManager) backUpConfigToExperimentDir() error {
+func (m *ArtemisManager) backUpConfigToExperimentDir(changed []string) error {
 	//save the config to the experiment dir
 	confSaveName := filepath.Join(m.experimentDir, "leto-final-config.yml")
-	return m.experimentConfig.WriteConfiguration(confSaveName)
+	if err := m.experimentConfig.WriteConfiguration(confSaveName); err != nil {
+		return err
+	}
+	return m.appendConfigurationHistory(changed)
+}
+
+// openAppend opens path for appending, as a restarted artemis logs
+// to the same files than the crashed one.
+func openAppend(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
 }
 
 func (m *ArtemisManager) setUpTrackerTask() error {
 	logFilePath := filepath.Join(m.experimentDir, "artemis.command")
-	artemisCommandLog, err := os.Create(logFilePath)
+	artemisCommandLog, err := openAppend(logFilePath)
 	if err != nil {
 		return fmt.Errorf("Could not create artemis log file ('%s'): %s", logFilePath, err)
 	}
 	defer artemisCommandLog.Close()
 
 	m.artemisCmd = m.buildTrackingCommand()
-	m.artemisCmd.Stderr, err = os.Create(filepath.Join(m.experimentDir, "artemis.stderr"))
+	m.artemisCmd.Stderr, err = openAppend(filepath.Join(m.experimentDir, "artemis.stderr"))
 	if err != nil {
 		return err
 	}
@@ -549,7 +742,7 @@ func (m *ArtemisManager) setUpTrackerTask() error {
 func (m *ArtemisManager) spawnFrameReadoutMergeTask() {
 	m.wg.Add(1)
 	go func() {
-		MergeFrameReadout(m.workBalance, m.incoming, m.merged)
+		MergeFrameReadoutWithReset(m.workBalance, m.incoming, m.mergeReset, m.merged)
 		m.wg.Done()
 	}()
 }
@@ -558,13 +751,20 @@ func (m *ArtemisManager) spawnFrameReadoutDispatchTask() {
 	m.wg.Add(1)
 	go func() {
 		for i := range m.merged {
+			// The file writer should always keep up, but if it
+			// stalls, tracking must go on. Dropped frames are
+			// recorded to not lose data silently, the file writer
+			// reports the frames it wrote.
 			select {
 			case m.file <- i:
 			default:
+				metrics.DispatchDropped("file")
+				m.frameGaps.Dropped(i.FrameID, "file writer not ready")
 			}
 			select {
 			case m.broadcast <- i:
 			default:
+				metrics.DispatchDropped("broadcast")
 			}
 		}
 		close(m.file)
@@ -576,7 +776,7 @@ func (m *ArtemisManager) spawnFrameReadoutDispatchTask() {
 func (m *ArtemisManager) spawnTrackerListenTask() {
 	m.trackerWg.Add(1)
 	go func() {
-		err := m.trackers.Listen(fmt.Sprintf(":%d", leto.ARTEMIS_IN_PORT), m.onTrackerAccept(), func() {
+		err := m.trackers.Listen(fmt.Sprintf(":%d", m.options.ArtemisInPort), m.onTrackerAccept(), func() {
 			m.logger.Printf("All connection closed, cleaning up experiment")
 		})
 		if err != nil {
@@ -589,7 +789,7 @@ func (m *ArtemisManager) spawnTrackerListenTask() {
 func (m *ArtemisManager) spawnFrameReadoutBroadCastTask() {
 	m.wg.Add(1)
 	go func() {
-		BroadcastFrameReadout(fmt.Sprintf(":%d", leto.ARTEMIS_OUT_PORT),
+		BroadcastFrameReadout(fmt.Sprintf(":%d", m.options.ArtemisOutPort),
 			m.broadcast,
 			3*time.Duration(1.0e6/(*m.experimentConfig.Camera.FPS))*time.Microsecond)
 		m.wg.Done()
@@ -611,44 +811,217 @@ func (m *ArtemisManager) spawnStreamTask() {
 	go m.streamManager.EncodeAndStreamMuxedStream(m.streamIn)
 }
 
+func (m *ArtemisManager) spawnDiskWatchTask() {
+	quit := make(chan struct{})
+	m.diskWatchQuit = quit
+	m.disk = nil
+	dir := m.experimentDir
+	go func() {
+		ticker := time.NewTicker(DISK_CHECK_PERIOD)
+		defer ticker.Stop()
+		for {
+			free, err := freeSpace(dir)
+			if err != nil {
+				m.logger.Printf("Could not check free space of '%s': %s", dir, err)
+			} else {
+				m.updateDiskStatus(free, quit)
+			}
+			select {
+			case <-quit:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+var diskLevelSeverity = map[leto.DiskLevel]int{
+	leto.DISK_OK:       0,
+	leto.DISK_WARNING:  1,
+	leto.DISK_CRITICAL: 2,
+}
+
+// updateDiskStatus records the free space of the experiment
+// filesystem, and publishes an event when its level gets worse. Once
+// critical, video saving is stopped if the options ask for it.
+func (m *ArtemisManager) updateDiskStatus(free uint64, quit <-chan struct{}) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	select {
+	case <-quit:
+		// the experiment is over
+		return
+	default:
+	}
+
+	thresholds := m.options.DiskThresholds()
+	previous := leto.DiskStatus{Level: leto.DISK_OK}
+	if m.disk != nil {
+		previous = *m.disk
+	}
+	m.disk = &leto.DiskStatus{
+		Free:               free,
+		Level:              thresholds.Level(free),
+		VideoSavingStopped: previous.VideoSavingStopped,
+	}
+	if diskLevelSeverity[m.disk.Level] <= diskLevelSeverity[previous.Level] {
+		return
+	}
+
+	if m.disk.Level == leto.DISK_WARNING {
+		m.events.Publish(leto.Event{
+			Type:    leto.EVENT_DISK_LOW,
+			Message: fmt.Sprintf("%s left (warning threshold: %s)", formatBytes(free), formatBytes(thresholds.Warning)),
+		})
+		return
+	}
+
+	e := leto.Event{
+		Type:    leto.EVENT_DISK_CRITICAL,
+		Message: fmt.Sprintf("%s left (critical threshold: %s)", formatBytes(free), formatBytes(thresholds.Critical)),
+	}
+	// the stream manager is owned by the teardown while stopping. It
+	// may also be nil while artemis is relaunched, the new one is then
+	// set up without saving.
+	if m.options.DiskStopVideo == true && m.state == leto.EXPERIMENT_RUNNING &&
+		m.nodeConfig.IsMaster() == true && m.disk.VideoSavingStopped == false {
+		if m.streamManager != nil {
+			m.streamManager.StopSaving()
+		}
+		m.disk.VideoSavingStopped = true
+		e.Message += ", video saving stopped"
+	}
+	m.logger.Printf("Disk space critical: %s", e.Message)
+	m.events.Publish(e)
+}
+
+// checkExperimentDiskSpace returns an error if the experiment, running
+// for planned, would leave less than the critical free space. Without
+// planned duration, the duration from the options is assumed. m.mx
+// does not need to be held while starting.
+func (m *ArtemisManager) checkExperimentDiskSpace(planned time.Duration) error {
+	if m.nodeConfig.IsMaster() == false {
+		// slaves only write their logs
+		return nil
+	}
+	if planned <= 0 {
+		planned = m.options.DiskDuration
+	}
+	if planned <= 0 {
+		return nil
+	}
+	free, err := freeSpace(m.experimentDir)
+	if err != nil {
+		m.logger.Printf("Could not check free space of '%s': %s", m.experimentDir, err)
+		return nil
+	}
+	estimate := estimateDiskUsage(m.experimentConfig, planned)
+	m.logger.Printf("Experiment needs about %s for %s, %s left", estimate, planned, formatBytes(free))
+	if err := checkDiskSpace(free, estimate, m.options.DiskThresholds().Critical); err != nil {
+		return &refusedError{fmt.Errorf("refusing to start for %s: %s", planned, err)}
+	}
+	return nil
+}
+
+func (m *ArtemisManager) tearDownDiskWatchTask() {
+	if m.diskWatchQuit != nil {
+		close(m.diskWatchQuit)
+		m.diskWatchQuit = nil
+	}
+}
+
+// checkSlavesCompatibility returns an error if a slave found on the
+// network could not track for this node. Missing slaves are reported
+// when they are started.
+func (m *ArtemisManager) checkSlavesCompatibility() error {
+	if len(m.nodeConfig.Slaves) == 0 {
+		return nil
+	}
+	nodes, err := leto.NewNodeLister().ListExpectedNodes(m.nodeConfig.Slaves, m.options.DiscoveryTimeout)
+	if err != nil {
+		m.logger.Printf("Could not list all slaves: %s", err)
+	}
+	return m.checkSlavesCapabilities(nodes)
+}
+
+func (m *ArtemisManager) checkSlavesCapabilities(nodes map[string]leto.Node) error {
+	for _, slaveName := range m.nodeConfig.Slaves {
+		slave, ok := nodes[slaveName]
+		if ok == false {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), m.options.RPCTimeout)
+		err := slave.CheckCompatible(ctx, m.options.requiredSlaveFeatures()...)
+		cancel()
+		var incompatible *leto.IncompatibleError
+		if errors.As(err, &incompatible) == true {
+			return &refusedError{fmt.Errorf("cannot start with slave '%s': %w", slaveName, err)}
+		}
+		if err != nil {
+			m.logger.Printf("Could not check slave %s: %s", slaveName, err)
+		}
+	}
+	return nil
+}
+
 func (m *ArtemisManager) startSlavesTrackers() {
 	if len(m.nodeConfig.Slaves) == 0 {
 		return
 	}
 
 	nl := leto.NewNodeLister()
-	nodes, err := nl.ListNodes()
+	nodes, err := nl.ListExpectedNodes(m.nodeConfig.Slaves, m.options.DiscoveryTimeout)
 	if err != nil {
-		m.logger.Printf("Could not list all local nodes: %s", err)
-		m.logger.Printf("Not starting slaves")
+		m.logger.Printf("Could not list all slaves: %s", err)
 	}
+	m.startSlaves(nodes)
+}
 
+// startSlaves starts the trackers of the slaves listed in nodes, each
+// with its own copy of the load balancing.
+func (m *ArtemisManager) startSlaves(nodes map[string]leto.Node) {
 	for _, slaveName := range m.nodeConfig.Slaves {
 		slave, ok := nodes[slaveName]
 		if ok == false {
 			m.logger.Printf("Could not find slave '%s', not starting it", slaveName)
+			m.events.Publish(leto.Event{
+				Type:    leto.EVENT_SLAVE_LOST,
+				Message: fmt.Sprintf("could not find slave '%s', not starting it", slaveName),
+			})
 			continue
 		}
 
 		slaveConfig := *m.experimentConfig
+		slaveConfig.Loads = m.experimentConfig.Loads.Copy()
 		slaveConfig.Loads.SelfUUID = slaveConfig.Loads.UUIDs[slaveName]
-		resp := leto.Response{}
-		err := slave.RunMethod("Leto.StartTracking", &slaveConfig, &resp)
-		if err == nil {
-			err = resp.ToError()
+		ctx, cancel := context.WithTimeout(context.Background(), m.options.RPCTimeout)
+		err := slave.CheckCompatible(ctx, m.options.requiredSlaveFeatures()...)
+		if err != nil {
+			cancel()
+			m.logger.Printf("Not starting slave %s: %s", slaveName, err)
+			m.events.Publish(leto.Event{
+				Type:    leto.EVENT_SLAVE_LOST,
+				Message: fmt.Sprintf("not starting slave '%s': %s", slaveName, err),
+			})
+			continue
 		}
+		err = slave.Call(ctx, "Leto.StartTracking", &slaveConfig, &leto.Response{})
+		cancel()
 		if err != nil {
 			m.logger.Printf("Could not start slave %s: %s", slaveName, err)
+			m.events.Publish(leto.Event{
+				Type:    leto.EVENT_SLAVE_LOST,
+				Message: fmt.Sprintf("could not start slave '%s': %s", slaveName, err),
+			})
 		}
 	}
 }
 
 func (m *ArtemisManager) stopSlavesTrackers() {
 	nl := leto.NewNodeLister()
-	nodes, err := nl.ListNodes()
+	nodes, err := nl.ListExpectedNodes(m.nodeConfig.Slaves, m.options.DiscoveryTimeout)
 	if err != nil {
-		m.logger.Printf("Could not list all local nodes: %s", err)
-		m.logger.Printf("Not stopping slaves")
+		m.logger.Printf("Could not list all slaves: %s", err)
 	}
 
 	for _, slaveName := range m.nodeConfig.Slaves {
@@ -657,11 +1030,9 @@ func (m *ArtemisManager) stopSlavesTrackers() {
 			m.logger.Printf("Could not find slave '%s', not stopping it", slaveName)
 			continue
 		}
-		resp := leto.Response{}
-		err := slave.RunMethod("Leto.StopTracking", &leto.NoArgs{}, &resp)
-		if err == nil {
-			err = resp.ToError()
-		}
+		ctx, cancel := context.WithTimeout(context.Background(), m.options.RPCTimeout)
+		err := slave.Call(ctx, "Leto.StopTracking", &leto.NoArgs{}, &leto.Response{})
+		cancel()
 		if err != nil {
 			m.logger.Printf("Could not stop slave %s: %s", slaveName, err)
 		}
@@ -695,18 +1066,49 @@ func (m *ArtemisManager) tearDownFilewriter() {
 	if m.fileWriter != nil {
 		m.fileWriter.Close()
 	}
+	if m.frameGaps != nil {
+		if err := m.frameGaps.Close(); err != nil {
+			m.logger.Printf("Could not close frame gaps file: %s", err)
+		}
+	}
 }
 
 func (m *ArtemisManager) tearDownStreamTask() {
-	if m.streamManager != nil {
-		m.logger.Printf("Waiting for stream tasks to stop")
-		m.artemisOut.Close()
-		m.streamManager.Wait()
-		m.streamManager = nil
-		m.streamIn.Close()
-		m.artemisOut = nil
-		m.streamIn = nil
+	m.detachStreamTask().wait()
+}
+
+// streamTask is a stream detached from the experiment, which can be
+// waited for without holding mx.
+type streamTask struct {
+	logger  *log.Logger
+	manager *StreamManager
+	in      *io.PipeReader
+	out     *io.PipeWriter
+}
+
+// detachStreamTask removes the stream task from m, to be stopped with
+// wait.
+func (m *ArtemisManager) detachStreamTask() streamTask {
+	res := streamTask{
+		logger:  m.logger,
+		manager: m.streamManager,
+		in:      m.streamIn,
+		out:     m.artemisOut,
 	}
+	m.streamManager = nil
+	m.artemisOut = nil
+	m.streamIn = nil
+	return res
+}
+
+func (t streamTask) wait() {
+	if t.manager == nil {
+		return
+	}
+	t.logger.Printf("Waiting for stream tasks to stop")
+	t.out.Close()
+	t.manager.Wait()
+	t.in.Close()
 }
 
 func (m *ArtemisManager) tearDownSubTasks() {
@@ -720,32 +1122,57 @@ func (m *ArtemisManager) tearDownSubTasks() {
 
 func (m *ArtemisManager) cleanUpGlobalVariables() {
 	m.artemisCmd = nil
+	m.stopTracker = nil
 	m.incoming = nil
 	m.merged = nil
+	m.mergeReset = nil
 	m.file = nil
 	m.broadcast = nil
 	m.trackers = nil
+	m.frameGaps = nil
 	m.artemisOut = nil
 	m.streamIn = nil
 	m.streamManager = nil
 	m.experimentConfig = nil
 	m.workBalance = nil
+	m.disk = nil
+	m.reloadTracker = false
+	m.revision = 0
 }
 
 func (m *ArtemisManager) tearDownExperiment(err error) {
 	m.mx.Lock()
-	defer m.mx.Unlock()
+	atomic.StoreInt32(&m.running, 0)
+	if m.state == leto.EXPERIMENT_RUNNING {
+		// artemis exited on its own
+		m.setState(leto.EXPERIMENT_STOPPING, nil)
+	}
 
 	if err != nil {
 		m.removePersistentFile()
 	}
 
 	m.lastExperimentLog = newExperimentLog(err != nil, m.since, m.experimentConfig, m.experimentDir)
+	m.lastExperimentLog.Crashes = m.crashes
+
+	m.tearDownDiskWatchTask()
+	m.mx.Unlock()
 
+	// waiting for the tasks does not need mx, as the state is
+	// leto.EXPERIMENT_STOPPING.
 	m.tearDownTrackerListenTask()
 	m.tearDownSubTasks()
 
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
 	m.logger.Printf("Experiment '%s' done", m.experimentConfig.ExperimentName)
+	stopped := leto.Event{Type: leto.EVENT_EXPERIMENT_STOPPED}
+	if err != nil {
+		stopped.Message = fmt.Sprintf("tracking failed: %s", err)
+	}
+	m.events.Publish(stopped)
+	m.events.SetExperiment("")
 
 	if m.testMode == true {
 		log.Printf("Cleaning '%s'", m.experimentDir)
@@ -755,27 +1182,217 @@ func (m *ArtemisManager) tearDownExperiment(err error) {
 	}
 
 	m.cleanUpGlobalVariables()
+
+	if err != nil {
+		m.setState(leto.EXPERIMENT_FAILED, fmt.Errorf("tracking failed: %s", err))
+	} else {
+		m.setState(leto.EXPERIMENT_IDLE, nil)
+	}
 }
 
 func (m *ArtemisManager) spawnLocalTracker() {
 	m.logger.Printf("Starting tracking for '%s'", m.experimentConfig.ExperimentName)
 	m.since = time.Now()
 
+	m.restarts = nil
+	m.crashes = nil
+	m.stopTracker = make(chan struct{})
+	stop := m.stopTracker
+
+	atomic.StoreInt32(&m.running, 1)
 	m.artemisWg.Add(1)
 	go func() {
-		err := m.artemisCmd.Run()
+		defer m.artemisWg.Done()
+		cmd := m.artemisCmd
+		err := cmd.Run()
+		for {
+			m.events.Publish(artemisExitedEvent(err))
+			var reloadErr error
+			if cmd, reloadErr = m.applyReload(); reloadErr != nil {
+				err = reloadErr
+				break
+			}
+			if cmd == nil {
+				cmd = m.restartLocalTracker(err, stop)
+			}
+			if cmd == nil {
+				break
+			}
+			err = cmd.Wait()
+		}
 		m.tearDownExperiment(err)
-		m.artemisWg.Done()
 	}()
 }
 
+// applyReload starts artemis again if it was stopped by Update. It
+// returns the started command, or nil if no reload was requested.
+func (m *ArtemisManager) applyReload() (*exec.Cmd, error) {
+	m.mx.Lock()
+	if m.reloadTracker == false || atomic.LoadInt32(&m.running) == 0 {
+		m.mx.Unlock()
+		return nil, nil
+	}
+	m.reloadTracker = false
+	m.mx.Unlock()
+
+	m.logger.Printf("Restarting artemis with the new configuration")
+	cmd, err := m.relaunchLocalTracker()
+	if err != nil {
+		return nil, fmt.Errorf("could not restart artemis: %s", err)
+	}
+	return cmd, nil
+}
+
+// restartLocalTracker records the crash of artemis with err and, if
+// the restart policy allows it, restarts it after the policy delay. It
+// returns the started command, or nil if the experiment should end.
+func (m *ArtemisManager) restartLocalTracker(err error, stop <-chan struct{}) *exec.Cmd {
+	if err == nil || atomic.LoadInt32(&m.running) == 0 {
+		return nil
+	}
+
+	m.mx.Lock()
+	now := time.Now()
+	delay, ok := m.options.RestartPolicy().Delay(m.restarts, now)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) == false {
+		// artemis could not be started, it would fail again.
+		ok = false
+	}
+	m.crashes = append(m.crashes, leto.ArtemisCrash{
+		Time:      now,
+		ExitCode:  exitCode(err),
+		Error:     err.Error(),
+		Restarted: ok,
+	})
+	m.mx.Unlock()
+
+	if ok == false {
+		m.logger.Printf("artemis crashed (%s), not restarting it", err)
+		return nil
+	}
+	m.logger.Printf("artemis crashed (%s), restarting it in %s", err, delay)
+
+	select {
+	case <-stop:
+		return nil
+	case <-time.After(delay):
+	}
+
+	m.mx.Lock()
+	if atomic.LoadInt32(&m.running) == 0 {
+		m.mx.Unlock()
+		return nil
+	}
+	m.restarts = append(m.restarts, time.Now())
+	restarts := len(m.restarts)
+	m.mx.Unlock()
+
+	cmd, relaunchErr := m.relaunchLocalTracker()
+	if relaunchErr != nil {
+		m.logger.Printf("Could not restart artemis: %s", relaunchErr)
+		m.mx.Lock()
+		m.crashes[len(m.crashes)-1].Restarted = false
+		m.mx.Unlock()
+		return nil
+	}
+	if cmd == nil {
+		return nil
+	}
+	m.events.Publish(leto.Event{
+		Type:    leto.EVENT_ARTEMIS_RESTARTED,
+		Message: fmt.Sprintf("restart %d after: %s", restarts, err),
+	})
+	return cmd
+}
+
+// relaunchLocalTracker starts a new artemis in the experiment
+// directory. On a master, frame readouts are synchronized again, and
+// new tracking and video file segments are started, as frame IDs
+// start over. The previous stream is waited for without holding mx.
+// It returns the started command, or nil if the experiment was
+// stopped meanwhile.
+func (m *ArtemisManager) relaunchLocalTracker() (*exec.Cmd, error) {
+	m.mx.Lock()
+	if stderr, ok := m.artemisCmd.Stderr.(*os.File); ok == true {
+		stderr.Close()
+	}
+	stream := streamTask{}
+	if m.nodeConfig.IsMaster() == true {
+		select {
+		case m.mergeReset <- struct{}{}:
+		default:
+		}
+		m.fileWriter.Rotate()
+		m.frameGaps.Reset()
+		stream = m.detachStreamTask()
+	}
+	m.mx.Unlock()
+
+	stream.wait()
+
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	if atomic.LoadInt32(&m.running) == 0 {
+		return nil, nil
+	}
+
+	if err := m.setUpTrackerTask(); err != nil {
+		return nil, err
+	}
+
+	if m.nodeConfig.IsMaster() == true {
+		if err := m.setUpStreamTask(); err != nil {
+			return nil, err
+		}
+		m.spawnStreamTask()
+	}
+
+	if err := m.artemisCmd.Start(); err != nil {
+		return nil, err
+	}
+	return m.artemisCmd, nil
+}
+
+// exitCode returns the exit code of artemis for the error returned by
+// exec.Cmd.Run, or -1 if it could not be started or was killed.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) == true {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+func artemisExitedEvent(err error) leto.Event {
+	code := exitCode(err)
+	res := leto.Event{Type: leto.EVENT_ARTEMIS_EXITED, ExitCode: &code}
+	if err != nil {
+		res.Message = err.Error()
+	}
+	return res
+}
+
+func (m *ArtemisManager) masterTarget() (string, int) {
+	if m.nodeConfig.IsMaster() == true {
+		return "localhost", m.options.ArtemisInPort
+	}
+	nodes, err := leto.NewNodeLister().ListExpectedNodes([]string{m.nodeConfig.Master}, m.options.DiscoveryTimeout)
+	if err == nil {
+		master := nodes[m.nodeConfig.Master]
+		return master.Address, master.ArtemisInPort
+	}
+	m.logger.Printf("Could not resolve master '%s' (%s), assuming default port", m.nodeConfig.Master, err)
+	return strings.TrimPrefix(m.nodeConfig.Master, "leto.") + ".local", leto.ARTEMIS_IN_PORT
+}
+
 func (m *ArtemisManager) buildTrackingCommand() *exec.Cmd {
 	args := []string{}
 
-	targetHost := "localhost"
-	if m.nodeConfig.IsMaster() == false {
-		targetHost = strings.TrimPrefix(m.nodeConfig.Master, "leto.") + ".local"
-	}
+	targetHost, targetPort := m.masterTarget()
 
 	if len(*m.experimentConfig.Camera.StubPaths) != 0 {
 		args = append(args, "--stub-image-paths", strings.Join(*m.experimentConfig.Camera.StubPaths, ","))
@@ -785,7 +1402,7 @@ func (m *ArtemisManager) buildTrackingCommand() *exec.Cmd {
 		args = append(args, "--test-mode")
 	}
 	args = append(args, "--host", targetHost)
-	args = append(args, "--port", fmt.Sprintf("%d", leto.ARTEMIS_IN_PORT))
+	args = append(args, "--port", fmt.Sprintf("%d", targetPort))
 	args = append(args, "--uuid", m.experimentConfig.Loads.SelfUUID)
 
 	if *m.experimentConfig.Threads > 0 {
@@ -859,7 +1476,7 @@ func (m *ArtemisManager) buildTrackingCommand() *exec.Cmd {
 	m.logger.Printf("args %s", args)
 	///////////////////////////////////////////////
 
-	cmd := exec.Command("artemis", args...)
+	cmd := exec.Command(m.options.ArtemisPath, args...)
 	cmd.Stderr = nil
 	cmd.Stdin = nil
 	return cmd
@@ -868,7 +1485,7 @@ func (m *ArtemisManager) buildTrackingCommand() *exec.Cmd {
 func (m *ArtemisManager) onTrackerAccept() func(c net.Conn) {
 	return func(c net.Conn) {
 		errors := make(chan error)
-		logger := log.New(os.Stderr, fmt.Sprintf("[artemis/%s] ", c.RemoteAddr().String()), 0)
+		logger := newLogger(fmt.Sprintf("[artemis/%s] ", c.RemoteAddr().String()))
 		logger.Printf("new connection from %s", c.RemoteAddr().String())
 		go func() {
 			for e := range errors {
@@ -876,6 +1493,12 @@ func (m *ArtemisManager) onTrackerAccept() func(c net.Conn) {
 			}
 		}()
 		FrameReadoutReadAll(c, m.incoming, errors)
+		if isLoopback(c.RemoteAddr()) == false && atomic.LoadInt32(&m.running) == 1 {
+			m.events.Publish(leto.Event{
+				Type:    leto.EVENT_SLAVE_LOST,
+				Message: fmt.Sprintf("tracker at %s disconnected during the experiment", c.RemoteAddr()),
+			})
+		}
 	}
 }
 
@@ -916,7 +1539,7 @@ func newExperimentLog(hasError bool,
 }
 
 func (m *ArtemisManager) persitentFilePath() string {
-	return filepath.Join(xdg.DataHome, "fort/leto/current-experiment.yml")
+	return filepath.Join(m.options.StateDir(), "current-experiment.yml")
 }
 
 func (m *ArtemisManager) writePersistentFile() {
@@ -925,12 +1548,7 @@ func (m *ArtemisManager) writePersistentFile() {
 		m.logger.Printf("Could not create data dir for '%s': %s", m.persitentFilePath(), err)
 		return
 	}
-	configData, err := yaml.Marshal(m.experimentConfig)
-	if err != nil {
-		m.logger.Printf("Could not marshal config data to persistent file: %s", err)
-		return
-	}
-	err = ioutil.WriteFile(m.persitentFilePath(), configData, 0644)
+	err = m.experimentConfig.WriteConfiguration(m.persitentFilePath())
 	if err != nil {
 		m.logger.Printf("Could not write persitent config file: %s", err)
 	}
@@ -944,13 +1562,11 @@ func (m *ArtemisManager) removePersistentFile() {
 }
 
 func (m *ArtemisManager) LoadFromPersistentFile() {
-	configData, err := ioutil.ReadFile(m.persitentFilePath())
-	if err != nil {
+	if _, err := os.Stat(m.persitentFilePath()); err != nil {
 		// if there is no file, there is nothing to load
 		return
 	}
-	config := &leto.TrackingConfiguration{}
-	err = yaml.Unmarshal(configData, config)
+	config, err := leto.ReadConfiguration(m.persitentFilePath())
 	if err != nil {
 		m.logger.Printf("Could not load configuration from '%s': %s", m.persitentFilePath(), err)
 		return
@@ -975,16 +1591,18 @@ func (m *ArtemisManager) timeoutGuard(f func() error, errPrefix string, d time.D
 	}
 }
 
-func (m *ArtemisManager) registerOlympus() {
-	m.timeoutGuard(m.registerOlympusError, "Could not register tracking to olympus: %s", 5*time.Second)
+func (m *ArtemisManager) registerOlympus(config *leto.TrackingConfiguration) {
+	m.timeoutGuard(func() error { return m.registerOlympusError(config) },
+		"Could not register tracking to olympus: %s", 5*time.Second)
 }
 
-func (m *ArtemisManager) unregisterOlympus() {
-	m.timeoutGuard(m.unregisterOlympusError, "Could not unregister tracking to olympus: %s", 5*time.Second)
+func (m *ArtemisManager) unregisterOlympus(config *leto.TrackingConfiguration) {
+	m.timeoutGuard(func() error { return m.unregisterOlympusError(config) },
+		"Could not unregister tracking to olympus: %s", 5*time.Second)
 }
 
-func (m *ArtemisManager) registerOlympusError() error {
-	olympusHost := m.experimentConfig.Stream.Host
+func (m *ArtemisManager) registerOlympusError(config *leto.TrackingConfiguration) error {
+	olympusHost := config.Stream.Host
 	if olympusHost == nil || len(*olympusHost) == 0 {
 		return nil
 	}
@@ -1005,12 +1623,12 @@ func (m *ArtemisManager) registerOlympusError() error {
 	return c.Call("Olympus.RegisterTracker", leto.RegisterTrackerArgs{
 		Hostname:       hostname,
 		StreamServer:   *olympusHost,
-		ExperimentName: m.experimentConfig.ExperimentName,
+		ExperimentName: config.ExperimentName,
 	}, &unused)
 }
 
-func (m *ArtemisManager) unregisterOlympusError() error {
-	olympusHost := m.experimentConfig.Stream.Host
+func (m *ArtemisManager) unregisterOlympusError(config *leto.TrackingConfiguration) error {
+	olympusHost := config.Stream.Host
 	if olympusHost == nil || len(*olympusHost) == 0 {
 		return nil
 	}
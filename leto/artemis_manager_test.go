@@ -1,6 +1,15 @@
 package main
 
 import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/rpc"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/formicidae-tracker/leto"
 	. "gopkg.in/check.v1"
 )
 
@@ -133,3 +142,110 @@ func (s *ArtemisManagerSuite) TestCheckFirmwareVariant(c *C) {
 		c.Check(err, ErrorMatches, d.Expected)
 	}
 }
+
+type fakeSlave struct {
+	capabilities leto.Capabilities
+
+	mx      sync.Mutex
+	started []*leto.TrackingConfiguration
+}
+
+func (s *fakeSlave) Capabilities(args *leto.NoArgs, resp *leto.Capabilities) error {
+	*resp = s.capabilities
+	return nil
+}
+
+func (s *fakeSlave) StartTracking(args *leto.TrackingConfiguration, resp *leto.Response) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.started = append(s.started, args)
+	return nil
+}
+
+func (s *fakeSlave) Started() []*leto.TrackingConfiguration {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.started
+}
+
+func serveFakeSlave(c *C, name string, capabilities leto.Capabilities) (leto.Node, *fakeSlave, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	router := rpc.NewServer()
+	slave := &fakeSlave{capabilities: capabilities}
+	c.Assert(router.RegisterName("Leto", slave), IsNil)
+	server := &http.Server{Handler: router}
+	go server.Serve(l)
+	return leto.Node{
+		Name:    name,
+		Address: "127.0.0.1",
+		Port:    l.Addr().(*net.TCPAddr).Port,
+	}, slave, func() { server.Close() }
+}
+
+func (s *ArtemisManagerSuite) TestRefusesIncompatibleSlaves(c *C) {
+	compatible, _, closeCompatible := serveFakeSlave(c, "foo", leto.LocalCapabilities())
+	defer closeCompatible()
+	old := leto.LocalCapabilities()
+	old.Features = nil
+	incompatible, _, closeIncompatible := serveFakeSlave(c, "bar", old)
+	defer closeIncompatible()
+
+	m := &ArtemisManager{
+		options:    DefaultOptions(),
+		logger:     newLogger("[artemis] "),
+		nodeConfig: NodeConfiguration{Slaves: []string{"foo", "bar", "baz"}},
+	}
+	nodes := map[string]leto.Node{"foo": compatible}
+	c.Check(m.checkSlavesCapabilities(nodes), IsNil)
+
+	nodes["bar"] = incompatible
+	c.Check(m.checkSlavesCapabilities(nodes), ErrorMatches,
+		"cannot start with slave 'bar': node 'bar' runs leto .*: missing feature\\(s\\) "+leto.FEATURE_SLAVE_TRACKING)
+}
+
+func argumentAfter(args []string, name string) string {
+	for i, a := range args[:len(args)-1] {
+		if a == name {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+func (s *ArtemisManagerSuite) TestRestartedMasterKeepsItsLoadBalancing(c *C) {
+	tmpDir, err := ioutil.TempDir("", "leto-artemis-manager-tests")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(tmpDir)
+	node, slave, closeSlave := serveFakeSlave(c, "foo", leto.LocalCapabilities())
+	defer closeSlave()
+
+	config := leto.RecommendedTrackingConfiguration()
+	m := &ArtemisManager{
+		options:          DefaultOptions(),
+		logger:           newLogger("[artemis] "),
+		events:           NewEventBroker("bar", EVENT_HISTORY_SIZE),
+		nodeConfig:       NodeConfiguration{Slaves: []string{"foo"}},
+		experimentConfig: &config,
+		experimentDir:    tmpDir,
+	}
+	m.experimentConfig.Loads = generateLoadBalancing(m.nodeConfig)
+	m.workBalance = buildWorkloadBalance(m.experimentConfig.Loads, *config.Camera.FPS)
+	masterUUID := m.experimentConfig.Loads.SelfUUID
+
+	m.startSlaves(map[string]leto.Node{"foo": node})
+	started := slave.Started()
+	c.Assert(started, HasLen, 1)
+	c.Check(started[0].Loads.SelfUUID, Equals, m.experimentConfig.Loads.UUIDs["foo"])
+	c.Check(started[0].Loads.SelfUUID, Not(Equals), masterUUID)
+
+	// a crashed artemis is relaunched with the command built for the
+	// master, after its slaves were started.
+	c.Assert(m.setUpTrackerTask(), IsNil)
+	defer m.artemisCmd.Stderr.(*os.File).Close()
+	c.Check(m.experimentConfig.Loads.SelfUUID, Equals, masterUUID)
+	c.Check(argumentAfter(m.artemisCmd.Args, "--uuid"), Equals, masterUUID)
+	c.Check(argumentAfter(m.artemisCmd.Args, "--frame-stride"), Equals, "2")
+	c.Check(argumentAfter(m.artemisCmd.Args, "--frame-ids"), Equals, "0")
+	c.Check(strings.Contains(strings.Join(m.artemisCmd.Args, " "), "--camera-slave-width"), Equals, false)
+}
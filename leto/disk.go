@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+
+	"github.com/formicidae-tracker/leto"
+)
+
+const GiB = 1024 * 1024 * 1024
+
+const DISK_CHECK_PERIOD = 1 * time.Minute
+
+// Rough sizes used to estimate the space needed by an experiment. They
+// are upper bounds for a typical colony, the critical threshold
+// provides an extra margin.
+const (
+	// TRACKING_BYTES_PER_FRAME is the size of a frame readout in
+	// the gzipped tracking files.
+	TRACKING_BYTES_PER_FRAME = 2 * 1024
+	// ESTIMATED_ANT_COUNT is the number of ants whose snapshot is
+	// saved every image renew period.
+	ESTIMATED_ANT_COUNT = 300
+)
+
+// DiskThresholds are the free space, in bytes, under which the disk
+// space is at a leto.DiskLevel.
+type DiskThresholds struct {
+	Warning, Critical uint64
+}
+
+// Level returns the level of free bytes.
+func (t DiskThresholds) Level(free uint64) leto.DiskLevel {
+	switch {
+	case free < t.Critical:
+		return leto.DISK_CRITICAL
+	case free < t.Warning:
+		return leto.DISK_WARNING
+	default:
+		return leto.DISK_OK
+	}
+}
+
+// DiskEstimate is the space in bytes needed by an experiment.
+type DiskEstimate struct {
+	Tracking, Video, Ants uint64
+}
+
+func (e DiskEstimate) Total() uint64 {
+	return e.Tracking + e.Video + e.Ants
+}
+
+func (e DiskEstimate) String() string {
+	return fmt.Sprintf("%s (tracking: %s, video: %s, ant images: %s)",
+		formatBytes(e.Total()), formatBytes(e.Tracking), formatBytes(e.Video), formatBytes(e.Ants))
+}
+
+// estimateDiskUsage estimates the space needed by a master or single
+// node to run config for d.
+func estimateDiskUsage(config *leto.TrackingConfiguration, d time.Duration) DiskEstimate {
+	seconds := d.Seconds()
+	res := DiskEstimate{
+		Tracking: uint64(*config.Camera.FPS * seconds * TRACKING_BYTES_PER_FRAME),
+		// the bitrate is in kbit/s
+		Video: uint64(float64(*config.Stream.BitRateKB) * 1000 / 8 * seconds),
+	}
+	if *config.NewAntRenewPeriod > 0 {
+		// grayscale PNG compresses to about half the pixels
+		roi := uint64(*config.NewAntOutputROISize)
+		snapshots := uint64(d / *config.NewAntRenewPeriod) + 1
+		res.Ants = snapshots * ESTIMATED_ANT_COUNT * roi * roi / 2
+	}
+	return res
+}
+
+// checkDiskSpace returns an error if, once estimate is written, less
+// than critical bytes would be free.
+func checkDiskSpace(free uint64, estimate DiskEstimate, critical uint64) error {
+	if free >= critical && free-critical >= estimate.Total() {
+		return nil
+	}
+	return fmt.Errorf("not enough free space: %s left, %s needed and %s must remain free",
+		formatBytes(free), estimate, formatBytes(critical))
+}
+
+// freeSpace returns the space available to unprivileged users on the
+// filesystem holding path.
+func freeSpace(path string) (uint64, error) {
+	stat := syscall.Statfs_t{}
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return stat.Bavail * uint64(stat.Bsize), nil
+}
+
+func formatBytes(b uint64) string {
+	return fmt.Sprintf("%.1f GiB", float64(b)/GiB)
+}
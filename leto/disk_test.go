@@ -0,0 +1,137 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/formicidae-tracker/leto"
+	. "gopkg.in/check.v1"
+)
+
+type DiskSuite struct {
+	tmpDir string
+	m      *ArtemisManager
+}
+
+var _ = Suite(&DiskSuite{})
+
+func (s *DiskSuite) SetUpTest(c *C) {
+	var err error
+	s.tmpDir, err = ioutil.TempDir("", "leto-disk-tests")
+	c.Assert(err, IsNil)
+	opts := DefaultOptions()
+	opts.DataDir = s.tmpDir
+	opts.DiskStopVideo = true
+	config := leto.RecommendedTrackingConfiguration()
+	events := NewEventBroker("foo", EVENT_HISTORY_SIZE)
+	s.m = &ArtemisManager{
+		options:          opts,
+		logger:           newLogger("[artemis] "),
+		stateChanged:     make(chan struct{}, 1),
+		events:           events,
+		state:            leto.EXPERIMENT_RUNNING,
+		experimentDir:    s.tmpDir,
+		experimentConfig: &config,
+	}
+	s.m.streamManager, err = NewStreamManager(opts.FFMpegPath, s.tmpDir, 8.0, config.Stream, events)
+	c.Assert(err, IsNil)
+}
+
+func (s *DiskSuite) TearDownTest(c *C) {
+	c.Check(os.RemoveAll(s.tmpDir), IsNil)
+}
+
+func (s *DiskSuite) TestLevels(c *C) {
+	t := DiskThresholds{Warning: 20 * GiB, Critical: 5 * GiB}
+	c.Check(t.Level(30*GiB), Equals, leto.DISK_OK)
+	c.Check(t.Level(20*GiB), Equals, leto.DISK_OK)
+	c.Check(t.Level(10*GiB), Equals, leto.DISK_WARNING)
+	c.Check(t.Level(1*GiB), Equals, leto.DISK_CRITICAL)
+	c.Check(DefaultOptions().DiskThresholds(), Equals, t)
+}
+
+func (s *DiskSuite) TestEstimate(c *C) {
+	config := leto.RecommendedTrackingConfiguration()
+	*config.Camera.FPS = 10
+	*config.Stream.BitRateKB = 2000
+	*config.NewAntOutputROISize = 100
+	*config.NewAntRenewPeriod = time.Hour
+
+	e := estimateDiskUsage(&config, 2*time.Hour)
+	c.Check(e, Equals, DiskEstimate{
+		Tracking: 10 * 7200 * TRACKING_BYTES_PER_FRAME,
+		Video:    250 * 1000 * 7200,
+		Ants:     3 * ESTIMATED_ANT_COUNT * 100 * 100 / 2,
+	})
+
+	c.Check(checkDiskSpace(e.Total()+5*GiB, e, 5*GiB), IsNil)
+	c.Check(checkDiskSpace(e.Total()+4*GiB, e, 5*GiB), ErrorMatches,
+		`not enough free space: 5\.8 GiB left, 1\.8 GiB \(tracking: 0\.1 GiB, video: 1\.7 GiB, ant images: 0\.0 GiB\) needed and 5\.0 GiB must remain free`)
+	c.Check(checkDiskSpace(GiB, DiskEstimate{}, 5*GiB), NotNil)
+}
+
+func (s *DiskSuite) TestStartIsRefusedWithoutSpace(c *C) {
+	s.m.options.DiskCritical = 1 << 40
+	s.m.options.DiskWarning = 1 << 40
+	err := s.m.checkExperimentDiskSpace(time.Hour)
+	c.Check(err, ErrorMatches, "refusing to start for 1h0m0s: not enough free space: .*")
+
+	// experiments without planned end can skip the check
+	s.m.options.DiskDuration = 0
+	c.Check(s.m.checkExperimentDiskSpace(0), IsNil)
+
+	// slaves only write their logs
+	s.m.nodeConfig.Master = "bar"
+	c.Check(s.m.checkExperimentDiskSpace(time.Hour), IsNil)
+}
+
+func (s *DiskSuite) TestWatchdog(c *C) {
+	_, events, unsubscribe := s.m.Events().Subscribe(nil)
+	defer unsubscribe()
+	quit := make(chan struct{})
+
+	s.m.updateDiskStatus(30*GiB, quit)
+	c.Check(s.m.Status().Experiment.Disk, DeepEquals, &leto.DiskStatus{Free: 30 * GiB, Level: leto.DISK_OK})
+
+	s.m.updateDiskStatus(10*GiB, quit)
+	e := <-events
+	c.Check(e.Type, Equals, leto.EVENT_DISK_LOW)
+	c.Check(e.Message, Equals, "10.0 GiB left (warning threshold: 20.0 GiB)")
+
+	// events are only published when the level gets worse
+	s.m.updateDiskStatus(9*GiB, quit)
+	s.m.updateDiskStatus(4*GiB, quit)
+	e = <-events
+	c.Check(e.Type, Equals, leto.EVENT_DISK_CRITICAL)
+	c.Check(e.Message, Equals, "4.0 GiB left (critical threshold: 5.0 GiB), video saving stopped")
+	c.Check(s.m.streamManager.saveStopped, Equals, true)
+
+	s.m.updateDiskStatus(30*GiB, quit)
+	c.Check(s.m.Status().Experiment.Disk, DeepEquals, &leto.DiskStatus{Free: 30 * GiB, Level: leto.DISK_OK, VideoSavingStopped: true})
+
+	close(quit)
+	s.m.updateDiskStatus(GiB, quit)
+	c.Check(s.m.disk.Free, Equals, uint64(30*GiB))
+	select {
+	case e := <-events:
+		c.Errorf("unexpected event %s", e)
+	default:
+	}
+}
+
+func (s *DiskSuite) TestRelaunchedStreamDoesNotSaveOnCriticalDisk(c *C) {
+	s.m.updateDiskStatus(GiB, make(chan struct{}))
+	c.Assert(s.m.streamManager.saveStopped, Equals, true)
+
+	// artemis restarts or highlights reloads set up a new stream
+	s.m.artemisCmd = exec.Command("true")
+	s.m.workBalance = &WorkloadBalance{Stride: 1}
+	c.Assert(s.m.setUpStreamTask(), IsNil)
+	c.Check(s.m.streamManager.saveStopped, Equals, true)
+
+	s.m.disk = nil
+	c.Assert(s.m.setUpStreamTask(), IsNil)
+	c.Check(s.m.streamManager.saveStopped, Equals, false)
+}
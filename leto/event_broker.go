@@ -0,0 +1,120 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/formicidae-tracker/leto"
+)
+
+// EVENT_HISTORY_SIZE is the number of past events kept for clients
+// resuming a stream.
+const EVENT_HISTORY_SIZE = 256
+
+// EventBroker publishes the events of a node to its subscribers. It
+// keeps a bounded history, so a client can resume a stream without
+// missing events. A subscriber that does not keep up is dropped. A
+// nil EventBroker discards all events.
+type EventBroker struct {
+	mx          sync.Mutex
+	node        string
+	experiment  string
+	seq         uint64
+	history     []leto.Event
+	size        int
+	subscribers map[chan leto.Event]struct{}
+	closed      bool
+}
+
+func NewEventBroker(node string, size int) *EventBroker {
+	return &EventBroker{
+		node:        node,
+		size:        size,
+		history:     make([]leto.Event, 0, size),
+		subscribers: make(map[chan leto.Event]struct{}),
+	}
+}
+
+// SetExperiment sets the experiment attached to subsequent events.
+func (b *EventBroker) SetExperiment(name string) {
+	if b == nil {
+		return
+	}
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	b.experiment = name
+}
+
+// Publish sets the sequence number, time, node and, if not set, the
+// experiment of e and sends it to all subscribers.
+func (b *EventBroker) Publish(e leto.Event) {
+	if b == nil {
+		return
+	}
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	b.seq += 1
+	e.Seq = b.seq
+	e.Time = time.Now()
+	e.Node = b.node
+	if len(e.Experiment) == 0 {
+		e.Experiment = b.experiment
+	}
+
+	if len(b.history) == b.size {
+		copy(b.history, b.history[1:])
+		b.history = b.history[:b.size-1]
+	}
+	b.history = append(b.history, e)
+
+	for s := range b.subscribers {
+		select {
+		case s <- e:
+		default:
+			// slow subscriber, it can resume from the history.
+			delete(b.subscribers, s)
+			close(s)
+		}
+	}
+}
+
+// Subscribe returns the events in history after since if not nil,
+// and a channel receiving the new events. The channel is closed when
+// unsubscribe is called, or if the subscriber does not keep up.
+func (b *EventBroker) Subscribe(since *uint64) ([]leto.Event, <-chan leto.Event, func()) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	var backlog []leto.Event
+	if since != nil {
+		for _, e := range b.history {
+			if e.Seq > *since {
+				backlog = append(backlog, e)
+			}
+		}
+	}
+	s := make(chan leto.Event, 64)
+	if b.closed == true {
+		close(s)
+		return backlog, s, func() {}
+	}
+	b.subscribers[s] = struct{}{}
+	return backlog, s, func() {
+		b.mx.Lock()
+		defer b.mx.Unlock()
+		if _, ok := b.subscribers[s]; ok == true {
+			delete(b.subscribers, s)
+			close(s)
+		}
+	}
+}
+
+// Close ends all subscriptions, current and future ones.
+func (b *EventBroker) Close() {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	b.closed = true
+	for s := range b.subscribers {
+		delete(b.subscribers, s)
+		close(s)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os/exec"
+
+	"github.com/formicidae-tracker/leto"
+	. "gopkg.in/check.v1"
+)
+
+type EventBrokerSuite struct{}
+
+var _ = Suite(&EventBrokerSuite{})
+
+func eventTypes(events []leto.Event) []string {
+	res := make([]string, 0, len(events))
+	for _, e := range events {
+		res = append(res, e.Type)
+	}
+	return res
+}
+
+func (s *EventBrokerSuite) TestKeepsABoundedHistory(c *C) {
+	b := NewEventBroker("foo", 3)
+	b.SetExperiment("bar")
+	for _, t := range []string{"a", "b", "c", "d"} {
+		b.Publish(leto.Event{Type: t})
+	}
+
+	backlog, _, unsubscribe := b.Subscribe(nil)
+	c.Check(backlog, HasLen, 0)
+	unsubscribe()
+
+	since := uint64(0)
+	backlog, _, unsubscribe = b.Subscribe(&since)
+	defer unsubscribe()
+	c.Check(eventTypes(backlog), DeepEquals, []string{"b", "c", "d"})
+	c.Check(backlog[0].Seq, Equals, uint64(2))
+	c.Check(backlog[0].Node, Equals, "foo")
+	c.Check(backlog[0].Experiment, Equals, "bar")
+
+	since = 3
+	backlog, _, unsubscribe = b.Subscribe(&since)
+	defer unsubscribe()
+	c.Check(eventTypes(backlog), DeepEquals, []string{"d"})
+}
+
+func (s *EventBrokerSuite) TestDropsSlowSubscribers(c *C) {
+	b := NewEventBroker("foo", 10)
+	_, events, unsubscribe := b.Subscribe(nil)
+	defer unsubscribe()
+	for i := 0; i < 65; i++ {
+		b.Publish(leto.Event{Type: "a"})
+	}
+	received := 0
+	for range events {
+		received += 1
+	}
+	c.Check(received, Equals, 64)
+}
+
+func (s *EventBrokerSuite) TestCloseEndsSubscriptions(c *C) {
+	b := NewEventBroker("foo", 10)
+	_, events, unsubscribe := b.Subscribe(nil)
+	b.Close()
+	_, ok := <-events
+	c.Check(ok, Equals, false)
+	unsubscribe()
+
+	_, events, unsubscribe = b.Subscribe(nil)
+	defer unsubscribe()
+	_, ok = <-events
+	c.Check(ok, Equals, false)
+
+	var nilBroker *EventBroker
+	nilBroker.Publish(leto.Event{Type: "a"})
+}
+
+func (s *EventBrokerSuite) TestReportsArtemisExitCode(c *C) {
+	e := artemisExitedEvent(nil)
+	c.Assert(e.ExitCode, Not(IsNil))
+	c.Check(*e.ExitCode, Equals, 0)
+
+	e = artemisExitedEvent(exec.Command("sh", "-c", "exit 3").Run())
+	c.Assert(e.ExitCode, Not(IsNil))
+	c.Check(*e.ExitCode, Equals, 3)
+	c.Check(e.Message, Equals, "exit status 3")
+
+	e = artemisExitedEvent(exec.Command("/does/not/exist").Run())
+	c.Check(*e.ExitCode, Equals, -1)
+}
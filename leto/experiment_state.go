@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/formicidae-tracker/leto"
+)
+
+// experimentTransitions are the legal transitions of the experiment
+// state of an ArtemisManager.
+var experimentTransitions = map[leto.ExperimentState][]leto.ExperimentState{
+	leto.EXPERIMENT_IDLE:     {leto.EXPERIMENT_STARTING},
+	leto.EXPERIMENT_FAILED:   {leto.EXPERIMENT_STARTING},
+	leto.EXPERIMENT_STARTING: {leto.EXPERIMENT_RUNNING, leto.EXPERIMENT_FAILED},
+	leto.EXPERIMENT_RUNNING:  {leto.EXPERIMENT_STOPPING},
+	leto.EXPERIMENT_STOPPING: {leto.EXPERIMENT_IDLE, leto.EXPERIMENT_FAILED},
+}
+
+func checkTransition(from, to leto.ExperimentState) error {
+	for _, s := range experimentTransitions[from] {
+		if s == to {
+			return nil
+		}
+	}
+	return fmt.Errorf("illegal experiment state transition from %s to %s", from, to)
+}
+
+// requireState returns a leto.StateError for operation if the current
+// state is not one of allowed. m.mx must be held.
+func (m *ArtemisManager) requireState(operation string, allowed ...leto.ExperimentState) error {
+	for _, s := range allowed {
+		if m.state == s {
+			return nil
+		}
+	}
+	return &leto.StateError{Operation: operation, State: m.state}
+}
+
+// setState transitions to state, with failure as the reason for
+// leto.EXPERIMENT_FAILED. Transitions are published as events. m.mx
+// must be held.
+func (m *ArtemisManager) setState(state leto.ExperimentState, failure error) {
+	if err := checkTransition(m.state, state); err != nil {
+		// it is a programming error, but it is safer to keep the
+		// daemon running.
+		m.logger.Printf("%s", err)
+	}
+	m.logger.Printf("Experiment state: %s -> %s", m.state, state)
+	m.state = state
+	m.failure = nil
+	if state == leto.EXPERIMENT_FAILED {
+		m.failure = failure
+	}
+	e := leto.Event{Type: leto.EVENT_STATE_CHANGED, State: state}
+	if m.failure != nil {
+		e.Message = m.failure.Error()
+	}
+	m.events.Publish(e)
+	m.notifyStateChange()
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/formicidae-tracker/leto"
+	. "gopkg.in/check.v1"
+)
+
+type ExperimentStateSuite struct {
+	tmpDir string
+	m      *ArtemisManager
+}
+
+var _ = Suite(&ExperimentStateSuite{})
+
+func (s *ExperimentStateSuite) SetUpTest(c *C) {
+	var err error
+	s.tmpDir, err = ioutil.TempDir("", "leto-experiment-state-tests")
+	c.Assert(err, IsNil)
+	opts := DefaultOptions()
+	opts.DataDir = s.tmpDir
+	opts.SiteConfigPath = filepath.Join(s.tmpDir, "leto.yml")
+	opts.NodeConfigPath = filepath.Join(s.tmpDir, "node.yml")
+	s.m = &ArtemisManager{
+		nodeConfig:   NodeConfiguration{Master: "bar"},
+		options:      opts,
+		logger:       newLogger("[artemis] "),
+		stateChanged: make(chan struct{}, 1),
+		events:       NewEventBroker("foo", EVENT_HISTORY_SIZE),
+		state:        leto.EXPERIMENT_IDLE,
+	}
+}
+
+func (s *ExperimentStateSuite) TearDownTest(c *C) {
+	c.Check(os.RemoveAll(s.tmpDir), IsNil)
+}
+
+func (s *ExperimentStateSuite) TestTransitions(c *C) {
+	testdata := []struct {
+		From, To leto.ExperimentState
+		Legal    bool
+	}{
+		{leto.EXPERIMENT_IDLE, leto.EXPERIMENT_STARTING, true},
+		{leto.EXPERIMENT_IDLE, leto.EXPERIMENT_RUNNING, false},
+		{leto.EXPERIMENT_STARTING, leto.EXPERIMENT_RUNNING, true},
+		{leto.EXPERIMENT_STARTING, leto.EXPERIMENT_FAILED, true},
+		{leto.EXPERIMENT_STARTING, leto.EXPERIMENT_STOPPING, false},
+		{leto.EXPERIMENT_RUNNING, leto.EXPERIMENT_STOPPING, true},
+		{leto.EXPERIMENT_RUNNING, leto.EXPERIMENT_IDLE, false},
+		{leto.EXPERIMENT_STOPPING, leto.EXPERIMENT_IDLE, true},
+		{leto.EXPERIMENT_STOPPING, leto.EXPERIMENT_FAILED, true},
+		{leto.EXPERIMENT_STOPPING, leto.EXPERIMENT_STARTING, false},
+		{leto.EXPERIMENT_FAILED, leto.EXPERIMENT_STARTING, true},
+	}
+	for _, d := range testdata {
+		err := checkTransition(d.From, d.To)
+		c.Check(err == nil, Equals, d.Legal, Commentf("%s -> %s", d.From, d.To))
+	}
+}
+
+func (s *ExperimentStateSuite) TestRejectsIllegalOperations(c *C) {
+	err := s.m.Stop()
+	var stateErr *leto.StateError
+	c.Assert(errors.As(err, &stateErr), Equals, true)
+	c.Check(stateErr.Operation, Equals, "stop the experiment")
+	c.Check(stateErr.State, Equals, leto.EXPERIMENT_IDLE)
+
+	s.m.state = leto.EXPERIMENT_STOPPING
+	err = s.m.Start(&leto.TrackingConfiguration{})
+	c.Check(err, ErrorMatches, "cannot start an experiment while the node is stopping")
+	c.Check(s.m.SetMaster(""), ErrorMatches, "cannot change master/slave configuration while the node is stopping")
+	c.Check(s.m.Status().State, Equals, leto.EXPERIMENT_STOPPING)
+}
+
+func (s *ExperimentStateSuite) TestFailedStart(c *C) {
+	_, events, unsubscribe := s.m.Events().Subscribe(nil)
+	defer unsubscribe()
+
+	c.Check(s.m.Start(&leto.TrackingConfiguration{}), NotNil)
+	status := s.m.Status()
+	c.Check(status.State, Equals, leto.EXPERIMENT_FAILED)
+	c.Check(status.Failure, Not(Equals), "")
+	c.Check(status.Experiment, IsNil)
+
+	for _, expected := range []leto.ExperimentState{leto.EXPERIMENT_STARTING, leto.EXPERIMENT_FAILED} {
+		e := <-events
+		c.Check(e.Type, Equals, leto.EVENT_STATE_CHANGED)
+		c.Check(e.State, Equals, expected)
+	}
+
+	// a failed node can be reconfigured
+	c.Check(s.m.SetMaster(""), IsNil)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/formicidae-tracker/leto"
+)
+
+// MAX_RECENT_FRAME_GAPS is the number of gaps reported in Leto.Status.
+const MAX_RECENT_FRAME_GAPS = 20
+
+// FrameGapRecorder records the frames that could not be written to
+// the tracking files. Consecutive dropped frames are merged in a
+// single leto.FrameGap, which is appended to a CSV sidecar file once
+// the gap ends. The file is only created if a frame is dropped.
+type FrameGapRecorder struct {
+	mx      sync.Mutex
+	path    string
+	file    *os.File
+	writer  *csv.Writer
+	current *leto.FrameGap
+	dropped uint64
+	recent  []leto.FrameGap
+	logger  *log.Logger
+}
+
+func NewFrameGapRecorder(path string) *FrameGapRecorder {
+	return &FrameGapRecorder{
+		path:   path,
+		logger: newLogger("[gaps] "),
+	}
+}
+
+// Dropped records that frameID was dropped for reason.
+func (r *FrameGapRecorder) Dropped(frameID int64, reason string) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.dropped += 1
+	if r.current != nil && r.current.Last+1 == frameID && r.current.Reason == reason {
+		r.current.Last = frameID
+		return
+	}
+	r.endGap()
+	r.current = &leto.FrameGap{
+		First:  frameID,
+		Last:   frameID,
+		Time:   time.Now(),
+		Reason: reason,
+	}
+}
+
+// Written records that frameID was written, ending the current gap
+// if frameID follows it. Frames queued before the gap do not end it.
+func (r *FrameGapRecorder) Written(frameID int64) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if r.current != nil && frameID < r.current.First {
+		return
+	}
+	r.endGap()
+}
+
+// Reset ends the current gap, as frame IDs start over once artemis is
+// relaunched and later frames could not end it.
+func (r *FrameGapRecorder) Reset() {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.endGap()
+}
+
+func (r *FrameGapRecorder) endGap() {
+	if r.current == nil {
+		return
+	}
+	gap := *r.current
+	r.current = nil
+	r.logger.Printf("frames %d to %d were not written: %s", gap.First, gap.Last, gap.Reason)
+
+	r.recent = append(r.recent, gap)
+	if len(r.recent) > MAX_RECENT_FRAME_GAPS {
+		r.recent = r.recent[1:]
+	}
+
+	if err := r.write(gap); err != nil {
+		r.logger.Printf("could not record gap in '%s': %s", r.path, err)
+	}
+}
+
+func (r *FrameGapRecorder) write(gap leto.FrameGap) error {
+	if r.writer == nil {
+		var err error
+		r.file, err = os.Create(r.path)
+		if err != nil {
+			return err
+		}
+		r.writer = csv.NewWriter(r.file)
+		r.writer.Write([]string{"first_frame", "last_frame", "frames", "time", "reason"})
+	}
+	r.writer.Write([]string{
+		fmt.Sprintf("%d", gap.First),
+		fmt.Sprintf("%d", gap.Last),
+		fmt.Sprintf("%d", gap.Frames()),
+		gap.Time.Format(time.RFC3339Nano),
+		gap.Reason,
+	})
+	r.writer.Flush()
+	return r.writer.Error()
+}
+
+// Status returns the number of dropped frames and the most recent
+// gaps, including the current one.
+func (r *FrameGapRecorder) Status() (uint64, []leto.FrameGap) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	gaps := make([]leto.FrameGap, 0, len(r.recent)+1)
+	gaps = append(gaps, r.recent...)
+	if r.current != nil {
+		gaps = append(gaps, *r.current)
+	}
+	if len(gaps) > MAX_RECENT_FRAME_GAPS {
+		gaps = gaps[1:]
+	}
+	return r.dropped, gaps
+}
+
+// Close ends the current gap and closes the sidecar file.
+func (r *FrameGapRecorder) Close() error {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.endGap()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	r.writer = nil
+	return err
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/formicidae-tracker/hermes"
+	. "gopkg.in/check.v1"
+)
+
+type FrameGapRecorderSuite struct {
+	tmpDir string
+}
+
+var _ = Suite(&FrameGapRecorderSuite{})
+
+func (s *FrameGapRecorderSuite) SetUpTest(c *C) {
+	var err error
+	s.tmpDir, err = ioutil.TempDir("", "leto-frame-gaps-tests")
+	c.Assert(err, IsNil)
+}
+
+func (s *FrameGapRecorderSuite) TearDownTest(c *C) {
+	c.Check(os.RemoveAll(s.tmpDir), IsNil)
+}
+
+func (s *FrameGapRecorderSuite) TestDoesNotCreateFileWithoutGaps(c *C) {
+	path := filepath.Join(s.tmpDir, "gaps.csv")
+	r := NewFrameGapRecorder(path)
+	r.Written(0)
+	c.Check(r.Close(), IsNil)
+	_, err := os.Stat(path)
+	c.Check(os.IsNotExist(err), Equals, true)
+}
+
+func (s *FrameGapRecorderSuite) TestRecordsGaps(c *C) {
+	path := filepath.Join(s.tmpDir, "gaps.csv")
+	r := NewFrameGapRecorder(path)
+	r.Written(2)
+	r.Dropped(3, "busy")
+	r.Dropped(4, "busy")
+	// a frame queued before the gap is written after it started
+	r.Written(2)
+	r.Dropped(5, "busy")
+	r.Written(6)
+	r.Dropped(10, "busy")
+	// not consecutive
+	r.Dropped(12, "busy")
+
+	dropped, gaps := r.Status()
+	c.Check(dropped, Equals, uint64(5))
+	c.Assert(gaps, HasLen, 3)
+	c.Check(gaps[0].First, Equals, int64(3))
+	c.Check(gaps[0].Last, Equals, int64(5))
+	c.Check(gaps[0].Frames(), Equals, int64(3))
+	c.Check(gaps[1].First, Equals, int64(10))
+	c.Check(gaps[1].Last, Equals, int64(10))
+	// the current gap is reported
+	c.Check(gaps[2].First, Equals, int64(12))
+
+	c.Check(r.Close(), IsNil)
+	data, err := ioutil.ReadFile(path)
+	c.Assert(err, IsNil)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	c.Assert(lines, HasLen, 4)
+	c.Check(lines[0], Equals, "first_frame,last_frame,frames,time,reason")
+	c.Check(lines[1], Matches, `3,5,3,[0-9TZ:\.\-\+]+,busy`)
+	c.Check(lines[2], Matches, `10,10,1,.*,busy`)
+	c.Check(lines[3], Matches, `12,12,1,.*,busy`)
+}
+
+func (s *FrameGapRecorderSuite) TestReportsOnlyRecentGaps(c *C) {
+	r := NewFrameGapRecorder(filepath.Join(s.tmpDir, "gaps.csv"))
+	defer r.Close()
+	for i := 0; i < 2*MAX_RECENT_FRAME_GAPS; i++ {
+		r.Dropped(int64(2*i), "busy")
+	}
+	dropped, gaps := r.Status()
+	c.Check(dropped, Equals, uint64(2*MAX_RECENT_FRAME_GAPS))
+	c.Assert(gaps, HasLen, MAX_RECENT_FRAME_GAPS)
+	c.Check(gaps[MAX_RECENT_FRAME_GAPS-1].First, Equals, int64(4*MAX_RECENT_FRAME_GAPS-2))
+}
+
+func (s *FrameGapRecorderSuite) TestFileWriterReportsWrittenFrames(c *C) {
+	r := NewFrameGapRecorder(filepath.Join(s.tmpDir, "gaps.csv"))
+	defer r.Close()
+	w, err := NewFrameReadoutWriter(filepath.Join(s.tmpDir, "tracking.hermes"), NewEventBroker("foo", 1), r)
+	c.Assert(err, IsNil)
+	r.Dropped(1, "busy")
+	readouts := make(chan *hermes.FrameReadout, 2)
+	readouts <- &hermes.FrameReadout{FrameID: 2}
+	readouts <- &hermes.FrameReadout{FrameID: 3}
+	close(readouts)
+	w.WriteAll(readouts)
+	dropped, gaps := r.Status()
+	c.Check(dropped, Equals, uint64(1))
+	c.Assert(gaps, HasLen, 1)
+	c.Check(gaps[0].Last, Equals, int64(1))
+	_, err = os.Stat(filepath.Join(s.tmpDir, "tracking.0000.hermes"))
+	c.Check(err, IsNil)
+}
+
+func (s *FrameGapRecorderSuite) TestFileWriterReportsFailedWrites(c *C) {
+	r := NewFrameGapRecorder(filepath.Join(s.tmpDir, "gaps.csv"))
+	defer r.Close()
+	w, err := NewFrameReadoutWriter(filepath.Join(s.tmpDir, "missing/tracking.hermes"), NewEventBroker("foo", 1), r)
+	c.Assert(err, IsNil)
+	readouts := make(chan *hermes.FrameReadout, 3)
+	for i := int64(1); i <= 3; i++ {
+		readouts <- &hermes.FrameReadout{FrameID: i}
+	}
+	close(readouts)
+	w.WriteAll(readouts)
+	dropped, gaps := r.Status()
+	c.Check(dropped, Equals, uint64(3))
+	c.Assert(gaps, HasLen, 1)
+	c.Check(gaps[0].First, Equals, int64(1))
+	c.Check(gaps[0].Last, Equals, int64(3))
+	c.Check(gaps[0].Reason, Matches, "file writer failed: .*")
+}
+
+func (s *FrameGapRecorderSuite) TestGapIsEndedWhenArtemisRestarts(c *C) {
+	path := filepath.Join(s.tmpDir, "gaps.csv")
+	r := NewFrameGapRecorder(path)
+	r.Written(9)
+	r.Dropped(10, "busy")
+	r.Dropped(11, "busy")
+	// frame IDs start over from 0
+	r.Reset()
+	r.Written(0)
+	data, err := ioutil.ReadFile(path)
+	c.Assert(err, IsNil)
+	c.Check(string(data), Matches, "first_frame,.*\n10,11,2,.*,busy\n")
+	r.Dropped(1, "busy")
+	r.Written(2)
+
+	dropped, gaps := r.Status()
+	c.Check(dropped, Equals, uint64(3))
+	c.Assert(gaps, HasLen, 2)
+	c.Check(gaps[0].First, Equals, int64(10))
+	c.Check(gaps[0].Last, Equals, int64(11))
+	c.Check(gaps[1].First, Equals, int64(1))
+	c.Check(gaps[1].Last, Equals, int64(1))
+
+	c.Check(r.Close(), IsNil)
+	data, err = ioutil.ReadFile(path)
+	c.Assert(err, IsNil)
+	c.Check(strings.Split(strings.TrimSpace(string(data)), "\n"), HasLen, 3)
+}
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"log"
 	"net"
-	"os"
 	"sync"
 	"time"
 
@@ -13,7 +12,7 @@ import (
 )
 
 func BroadcastFrameReadout(address string, readouts <-chan *hermes.FrameReadout, idle time.Duration) error {
-	logger := log.New(os.Stderr, "[broadcast] ", 0)
+	logger := newLogger("[broadcast] ")
 	m := NewRemoteManager()
 
 	mx := sync.RWMutex{}
@@ -51,7 +50,7 @@ func BroadcastFrameReadout(address string, readouts <-chan *hermes.FrameReadout,
 	logger.Printf("Broadcasting on %s", address)
 	return m.Listen(address, func(c net.Conn) {
 		defer c.Close()
-		logger := log.New(os.Stderr, fmt.Sprintf("[broadcast/%s] ", c.RemoteAddr().String()), 0)
+		logger := newLogger(fmt.Sprintf("[broadcast/%s] ", c.RemoteAddr().String()))
 
 		b := proto.NewBuffer(nil)
 		header := &hermes.Header{
@@ -74,6 +73,8 @@ func BroadcastFrameReadout(address string, readouts <-chan *hermes.FrameReadout,
 		outgoing[idx] = o
 		i += 1
 		mx.Unlock()
+		metrics.AddBroadcastClients(1)
+		defer metrics.AddBroadcastClients(-1)
 		for buf := range o {
 			c.SetWriteDeadline(time.Now().Add(idle))
 			_, err := c.Write(buf)
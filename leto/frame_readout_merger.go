@@ -2,8 +2,6 @@ package main
 
 import (
 	"fmt"
-	"log"
-	"os"
 	"sort"
 	"time"
 
@@ -33,8 +31,7 @@ func (wb *WorkloadBalance) Check() error {
 	if len(wb.MasterUUID) == 0 {
 		return fmt.Errorf("Work Balance is missing master UUID")
 	}
-	wb.offsets = make(map[string]float64)
-	wb.lastPoint = nil
+	wb.resetSynchronization()
 	fids := map[int]string{}
 
 	if len(wb.IDsByUUID) > wb.Stride {
@@ -65,6 +62,11 @@ func (wb *WorkloadBalance) Check() error {
 	return nil
 }
 
+func (wb *WorkloadBalance) resetSynchronization() {
+	wb.offsets = make(map[string]float64)
+	wb.lastPoint = nil
+}
+
 func (wb *WorkloadBalance) FrameID(ID int64) int {
 	return int(ID % int64(wb.Stride))
 }
@@ -109,6 +111,7 @@ func (wb *WorkloadBalance) CheckFrame(f *hermes.FrameReadout) (int, error) {
 			offset += 0.2 * (currentOffset - offset)
 		}
 		wb.offsets[f.ProducerUuid] = offset
+		metrics.SetClockOffset(f.ProducerUuid, offset)
 		f.Timestamp += int64(offset)
 	}
 	return fid, nil
@@ -129,6 +132,14 @@ func (r ReadoutBuffer) Less(i, j int) bool {
 }
 
 func MergeFrameReadout(wb *WorkloadBalance, inbound <-chan *hermes.FrameReadout, outbound chan<- *hermes.FrameReadout) error {
+	return MergeFrameReadoutWithReset(wb, inbound, nil, outbound)
+}
+
+// MergeFrameReadoutWithReset is MergeFrameReadout, but each signal on
+// reset drops the pending frames and synchronizes again on the next
+// received frame. It is needed when artemis restarts, as frame IDs
+// start over.
+func MergeFrameReadoutWithReset(wb *WorkloadBalance, inbound <-chan *hermes.FrameReadout, reset <-chan struct{}, outbound chan<- *hermes.FrameReadout) error {
 	defer close(outbound)
 
 	if err := wb.Check(); err != nil {
@@ -143,7 +154,7 @@ func MergeFrameReadout(wb *WorkloadBalance, inbound <-chan *hermes.FrameReadout,
 	betweenFrame := time.Duration(1.0e9/wb.FPS) * time.Nanosecond
 	timeout := time.Duration(2*wb.Stride+2) * betweenFrame
 
-	logger := log.New(os.Stderr, "[FrameReadoutMerger] ", 0)
+	logger := newLogger("[FrameReadoutMerger] ")
 	for {
 		var timer *time.Timer = nil
 		var timeoutC <-chan time.Time = nil
@@ -189,6 +200,18 @@ func MergeFrameReadout(wb *WorkloadBalance, inbound <-chan *hermes.FrameReadout,
 
 		case t := <-timeoutC:
 			now = t
+		case <-reset:
+			if len(buffer) > 0 {
+				logger.Printf("Reset: dropping %d pending frames", len(buffer))
+			}
+			buffer = buffer[:0]
+			deadlines = map[int64]time.Time{}
+			maxFrame = -1
+			wb.resetSynchronization()
+			if timer != nil {
+				timer.Stop()
+			}
+			continue
 		}
 		if timer != nil {
 			timer.Stop()
@@ -204,6 +227,7 @@ func MergeFrameReadout(wb *WorkloadBalance, inbound <-chan *hermes.FrameReadout,
 			if ok == true && now.After(d) == true {
 				nowPb, _ := ptypes.TimestampProto(now)
 				logger.Printf("marking frame %d as timeouted", i)
+				metrics.MergerTimeout()
 				ro := &hermes.FrameReadout{
 					Error:   hermes.FrameReadout_PROCESS_TIMEOUT,
 					FrameID: i,
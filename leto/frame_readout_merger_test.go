@@ -367,3 +367,43 @@ func (s *FrameReadoutMergerSuite) TestEnd2End(c *C) {
 	wg.Wait()
 
 }
+
+func (s *FrameReadoutMergerSuite) TestResetSynchronizesOnNextFrame(c *C) {
+	wb := &WorkloadBalance{
+		FPS:        1.0,
+		Stride:     1,
+		MasterUUID: "foo",
+		IDsByUUID: map[string][]bool{
+			"foo": []bool{true},
+		},
+	}
+	inbound := make(chan *hermes.FrameReadout)
+	outbound := make(chan *hermes.FrameReadout)
+	reset := make(chan struct{})
+	go func() {
+		c.Check(MergeFrameReadoutWithReset(wb, inbound, reset, outbound), IsNil)
+	}()
+
+	for _, ID := range []int64{10, 11} {
+		inbound <- &hermes.FrameReadout{FrameID: ID, ProducerUuid: "foo"}
+		r := <-outbound
+		c.Check(r.FrameID, Equals, ID)
+	}
+
+	// a restarted artemis starts over from frame 0, which would
+	// otherwise be considered as already timeouted.
+	reset <- struct{}{}
+	for _, ID := range []int64{0, 1} {
+		inbound <- &hermes.FrameReadout{FrameID: ID, ProducerUuid: "foo"}
+		select {
+		case r := <-outbound:
+			c.Check(r.FrameID, Equals, ID)
+		case <-time.After(time.Second):
+			c.Fatalf("frame %d was not sent after reset", ID)
+		}
+	}
+
+	close(inbound)
+	_, ok := <-outbound
+	c.Check(ok, Equals, false)
+}
@@ -22,6 +22,7 @@ func FrameReadoutReadAll(stream io.Reader, C chan<- *hermes.FrameReadout, E chan
 			E <- err
 		}
 		if ok == true {
+			metrics.FrameReceived(m.ProducerUuid)
 			C <- m
 		}
 	}
@@ -3,12 +3,14 @@ package main
 import (
 	"compress/gzip"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"time"
 
 	"github.com/formicidae-tracker/hermes"
+	"github.com/formicidae-tracker/leto"
 	"github.com/golang/protobuf/proto"
 )
 
@@ -20,26 +22,45 @@ type FrameReadoutFileWriter struct {
 	gzip     *gzip.Writer
 	logger   *log.Logger
 	quit     chan struct{}
+	rotate   chan struct{}
+	events   *EventBroker
+	gaps     *FrameGapRecorder
 }
 
-func NewFrameReadoutWriter(filepath string) (*FrameReadoutFileWriter, error) {
+// NewFrameReadoutWriter creates a writer to filepath, which reports
+// to gaps the readouts it wrote or failed to write.
+func NewFrameReadoutWriter(filepath string, events *EventBroker, gaps *FrameGapRecorder) (*FrameReadoutFileWriter, error) {
 
 	return &FrameReadoutFileWriter{
 		period:   2 * time.Hour,
 		basename: filepath,
 		quit:     make(chan struct{}),
-		logger:   log.New(os.Stderr, fmt.Sprintf("[file/%s] ", filepath), log.LstdFlags),
+		rotate:   make(chan struct{}, 1),
+		events:   events,
+		gaps:     gaps,
+		logger:   newLogger(fmt.Sprintf("[file/%s] ", filepath)),
 	}, nil
 
 }
 
+// countingWriter reports the bytes written to the metrics.
+type countingWriter struct {
+	w io.Writer
+}
+
+func (c countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	metrics.FileBytesWritten(n)
+	return n, err
+}
+
 func (w *FrameReadoutFileWriter) openFile(filep string, width, height int32) error {
 	var err error
 	w.file, err = os.Create(filep)
 	if err != nil {
 		return err
 	}
-	w.gzip = gzip.NewWriter(w.file)
+	w.gzip = gzip.NewWriter(countingWriter{w.file})
 
 	header := &hermes.Header{
 		Type: hermes.Header_File,
@@ -52,6 +73,11 @@ func (w *FrameReadoutFileWriter) openFile(filep string, width, height int32) err
 	}
 	if len(w.lastname) > 0 {
 		header.Previous = filepath.Base(w.lastname)
+		w.events.Publish(leto.Event{
+			Type:    leto.EVENT_FILE_ROTATED,
+			Path:    filepath.Base(filep),
+			Message: fmt.Sprintf("follows %s", header.Previous),
+		})
 	}
 
 	w.lastname = filep
@@ -109,6 +135,15 @@ func (w *FrameReadoutFileWriter) Close() error {
 	return nil
 }
 
+// Rotate requests the current file to be closed, so the next readout
+// starts a new file, linked to the previous one.
+func (w *FrameReadoutFileWriter) Rotate() {
+	select {
+	case w.rotate <- struct{}{}:
+	default:
+	}
+}
+
 func (w *FrameReadoutFileWriter) WriteAll(readout <-chan *hermes.FrameReadout) {
 	ticker := time.NewTicker(w.period)
 	defer func() {
@@ -117,10 +152,13 @@ func (w *FrameReadoutFileWriter) WriteAll(readout <-chan *hermes.FrameReadout) {
 	}()
 
 	closeNext := false
+	// once writing failed, readouts are still consumed to report
+	// them as dropped.
+	var failure error
 	nextName, _, err := FilenameWithoutOverwrite(w.basename)
 	if err != nil {
 		w.logger.Printf("Could not find unique name: %s", err)
-		return
+		failure = err
 	}
 
 	for {
@@ -129,15 +167,31 @@ func (w *FrameReadoutFileWriter) WriteAll(readout <-chan *hermes.FrameReadout) {
 			closeNext = true
 		case <-w.quit:
 			return
+		case <-w.rotate:
+			if w.file == nil || failure != nil {
+				continue
+			}
+			closeNext = false
+			nextName, _, err = FilenameWithoutOverwrite(w.basename)
+			if err != nil {
+				w.logger.Printf("Could not find unique name: %s", err)
+			}
+			w.closeFiles(nextName)
 		case r, ok := <-readout:
 			if ok == false {
 				return
 			}
+			if failure != nil {
+				w.gaps.Dropped(r.FrameID, fmt.Sprintf("file writer failed: %s", failure))
+				continue
+			}
 			if w.file == nil {
 				err := w.openFile(nextName, r.Width, r.Height)
 				if err != nil {
 					w.logger.Printf("Could not create file '%s': %s", nextName, err)
-					return
+					failure = err
+					w.gaps.Dropped(r.FrameID, fmt.Sprintf("file writer failed: %s", failure))
+					continue
 				}
 			}
 
@@ -161,12 +215,17 @@ func (w *FrameReadoutFileWriter) WriteAll(readout <-chan *hermes.FrameReadout) {
 			}
 			if err := b.EncodeMessage(line); err != nil {
 				w.logger.Printf("Could not encode message: %s", err)
+				w.gaps.Dropped(r.FrameID, "could not encode readout")
+				continue
 			}
 			_, err := w.gzip.Write(b.Bytes())
 			if err != nil {
 				w.logger.Printf("Could not write message: %s", err)
-				return
+				failure = err
+				w.gaps.Dropped(r.FrameID, fmt.Sprintf("file writer failed: %s", failure))
+				continue
 			}
+			w.gaps.Written(r.FrameID)
 			if closeNext == false {
 				continue
 			}
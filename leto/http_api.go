@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/rpc"
+	"strconv"
+
+	"github.com/formicidae-tracker/leto"
+)
+
+// API_PREFIX is the root of the REST API, served alongside net/rpc.
+const API_PREFIX = "/api/v1"
+
+// restAPI exposes the Leto RPC methods as a REST/JSON API. Both
+// interfaces share the same Leto and ArtemisManager.
+type restAPI struct {
+	leto *Leto
+}
+
+func newHTTPHandler(l *Leto, rpcRouter *rpc.Server) http.Handler {
+	api := &restAPI{leto: l}
+	mux := http.NewServeMux()
+	mux.Handle(rpc.DefaultRPCPath, rpcRouter)
+	// net/rpc only registers its debug page on http.DefaultServeMux,
+	// see rpc.Server.HandleHTTP.
+	mux.Handle(rpc.DefaultDebugPath, http.DefaultServeMux)
+	mux.Handle("/metrics", metrics)
+	mux.HandleFunc(API_PREFIX+"/status", api.get(api.status))
+	mux.HandleFunc(API_PREFIX+"/last-experiment-log", api.get(api.lastExperimentLog))
+	mux.HandleFunc(API_PREFIX+"/start", api.post(api.start))
+	mux.HandleFunc(API_PREFIX+"/stop", api.post(api.stop))
+	mux.HandleFunc(API_PREFIX+"/link", api.post(api.link))
+	mux.HandleFunc(API_PREFIX+"/unlink", api.post(api.unlink))
+	mux.HandleFunc(leto.EVENTS_PATH, api.events)
+	return mux
+}
+
+// apiError is an error with its HTTP status code.
+type apiError struct {
+	code int
+	err  error
+}
+
+type apiHandler func(r *http.Request) (interface{}, *apiError)
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (a *restAPI) handle(method string, h apiHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			writeJSON(w, http.StatusMethodNotAllowed, leto.Response{Error: fmt.Sprintf("method %s not allowed", r.Method)})
+			return
+		}
+		res, aerr := h(r)
+		if aerr != nil {
+			writeJSON(w, aerr.code, leto.Response{Error: aerr.err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, res)
+	}
+}
+
+func (a *restAPI) get(h apiHandler) http.HandlerFunc {
+	return a.handle(http.MethodGet, h)
+}
+
+func (a *restAPI) post(h apiHandler) http.HandlerFunc {
+	return a.handle(http.MethodPost, h)
+}
+
+func decodeBody(r *http.Request, v interface{}) *apiError {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil && err != io.EOF {
+		return &apiError{http.StatusBadRequest, fmt.Errorf("invalid request body: %s", err)}
+	}
+	return nil
+}
+
+// response converts the outcome of a Leto method to the API result.
+// Requests refused in the current state of the node, like a
+// *leto.StateError, are conflicts. Errors of the slaves a request was
+// forwarded to are gateway errors, and unexpected errors, like
+// filesystem failures, internal errors.
+func response(err error) (interface{}, *apiError) {
+	if err == nil {
+		return leto.Response{}, nil
+	}
+	return nil, &apiError{errorStatus(err), err}
+}
+
+func errorStatus(err error) int {
+	var invalidRequest *invalidRequestError
+	var invalidConfig *configurationError
+	var unknownProfile *unknownProfileError
+	var stateErr *leto.StateError
+	var refused *refusedError
+	var incompatible *leto.IncompatibleError
+	var timeout *leto.TimeoutError
+	var unreachable *leto.UnreachableError
+	var missing *leto.MissingNodesError
+	var remote *leto.RemoteError
+	switch {
+	case errors.As(err, &invalidRequest):
+		return http.StatusBadRequest
+	case errors.As(err, &invalidConfig):
+		return http.StatusUnprocessableEntity
+	case errors.As(err, &unknownProfile):
+		return http.StatusNotFound
+	case errors.As(err, &stateErr), errors.As(err, &refused), errors.As(err, &incompatible):
+		return http.StatusConflict
+	case errors.As(err, &timeout):
+		return http.StatusGatewayTimeout
+	case errors.As(err, &unreachable), errors.As(err, &missing), errors.As(err, &remote):
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (a *restAPI) status(r *http.Request) (interface{}, *apiError) {
+	status := leto.Status{}
+	a.leto.Status(&leto.NoArgs{}, &status)
+	return status, nil
+}
+
+func (a *restAPI) lastExperimentLog(r *http.Request) (interface{}, *apiError) {
+	var log *leto.ExperimentLog
+	a.leto.LastExperimentLog(&leto.NoArgs{}, &log)
+	if log == nil {
+		return nil, &apiError{http.StatusNotFound, fmt.Errorf("no experiment was run")}
+	}
+	return log, nil
+}
+
+func (a *restAPI) start(r *http.Request) (interface{}, *apiError) {
+	args := leto.TrackingStart{}
+	if err := decodeBody(r, &args); err != nil {
+		return nil, err
+	}
+	if len(args.Profile) > 0 {
+		return response(a.leto.startTrackingProfile(&leto.ProfileTrackingStart{
+			Profile:       args.Profile,
+			YamlOverrides: args.YamlConfiguration,
+		}))
+	}
+	config, err := leto.ParseConfiguration([]byte(args.YamlConfiguration))
+	if err != nil {
+		return nil, &apiError{http.StatusBadRequest, fmt.Errorf("invalid configuration: %s", err)}
+	}
+	return response(a.leto.startTracking(config))
+}
+
+func (a *restAPI) stop(r *http.Request) (interface{}, *apiError) {
+	a.leto.logger.Printf("new stop request")
+	return response(a.leto.artemis.Stop())
+}
+
+func (a *restAPI) link(r *http.Request) (interface{}, *apiError) {
+	args := leto.Link{}
+	if err := decodeBody(r, &args); err != nil {
+		return nil, err
+	}
+	return response(a.leto.link(&args))
+}
+
+func (a *restAPI) unlink(r *http.Request) (interface{}, *apiError) {
+	args := leto.Link{}
+	if err := decodeBody(r, &args); err != nil {
+		return nil, err
+	}
+	return response(a.leto.unlink(&args))
+}
+
+// events streams the node events as one JSON object per line, see
+// leto.RPCClient.FollowEvents.
+func (a *restAPI) events(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeJSON(w, http.StatusMethodNotAllowed, leto.Response{Error: fmt.Sprintf("method %s not allowed", r.Method)})
+		return
+	}
+	var since *uint64
+	if value := r.URL.Query().Get("since"); len(value) > 0 {
+		seq, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, leto.Response{Error: fmt.Sprintf("invalid since: %s", err)})
+			return
+		}
+		since = &seq
+	}
+
+	backlog, events, unsubscribe := a.leto.artemis.Events().Subscribe(since)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	flush := func() {
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	for _, e := range backlog {
+		if enc.Encode(e) != nil {
+			return
+		}
+	}
+	flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-events:
+			if ok == false {
+				return
+			}
+			if enc.Encode(e) != nil {
+				return
+			}
+			flush()
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/rpc"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/formicidae-tracker/leto"
+	. "gopkg.in/check.v1"
+)
+
+type RESTAPISuite struct {
+	tmpDir string
+	leto   *Leto
+	server *httptest.Server
+}
+
+var _ = Suite(&RESTAPISuite{})
+
+func (s *RESTAPISuite) SetUpSuite(c *C) {
+	// like Execute, registers the net/rpc debug page once
+	router := rpc.NewServer()
+	c.Assert(router.Register(&Leto{}), IsNil)
+	router.HandleHTTP("/leto-rest-api-tests/rpc", rpc.DefaultDebugPath)
+}
+
+func (s *RESTAPISuite) SetUpTest(c *C) {
+	var err error
+	s.tmpDir, err = ioutil.TempDir("", "leto-rest-api-tests")
+	c.Assert(err, IsNil)
+	opts := DefaultOptions()
+	opts.Name = "foo"
+	opts.DataDir = s.tmpDir
+	opts.SiteConfigPath = filepath.Join(s.tmpDir, "leto.yml")
+	opts.NodeConfigPath = filepath.Join(s.tmpDir, "node.yml")
+	s.leto = &Leto{
+		options:  opts,
+		profiles: NewProfileStore(filepath.Join(s.tmpDir, "profiles")),
+		logger:   newLogger("[rpc] "),
+		artemis: &ArtemisManager{
+			nodeConfig:   NodeConfiguration{Master: "bar"},
+			options:      opts,
+			logger:       newLogger("[artemis] "),
+			stateChanged: make(chan struct{}, 1),
+			events:       NewEventBroker("foo", EVENT_HISTORY_SIZE),
+			state:        leto.EXPERIMENT_IDLE,
+		},
+	}
+	router := rpc.NewServer()
+	c.Assert(router.Register(s.leto), IsNil)
+	s.server = httptest.NewServer(newHTTPHandler(s.leto, router))
+}
+
+func (s *RESTAPISuite) TearDownTest(c *C) {
+	s.server.Close()
+	c.Check(os.RemoveAll(s.tmpDir), IsNil)
+}
+
+func (s *RESTAPISuite) request(c *C, method, path, body string, reply interface{}) int {
+	req, err := http.NewRequest(method, s.server.URL+API_PREFIX+path, strings.NewReader(body))
+	c.Assert(err, IsNil)
+	resp, err := http.DefaultClient.Do(req)
+	c.Assert(err, IsNil)
+	defer resp.Body.Close()
+	c.Check(resp.Header.Get("Content-Type"), Equals, "application/json")
+	c.Assert(json.NewDecoder(resp.Body).Decode(reply), IsNil)
+	return resp.StatusCode
+}
+
+func (s *RESTAPISuite) TestStatusSchema(c *C) {
+	status := map[string]interface{}{}
+	c.Assert(s.request(c, "GET", "/status", "", &status), Equals, http.StatusOK)
+	c.Check(status["master"], Equals, "bar")
+	c.Check(status["slaves"], IsNil)
+	c.Check(status["experiment"], IsNil)
+	c.Check(status["site_configuration_error"], Equals, "")
+	c.Check(status["state"], Equals, "idle")
+	c.Check(status["failure"], Equals, "")
+	c.Check(status, HasLen, 6)
+
+	since := time.Date(2021, 03, 04, 10, 00, 00, 00, time.UTC)
+	data, err := json.Marshal(leto.ExperimentStatus{
+		Since:             since,
+		ExperimentDir:     "foo.0000",
+		YamlConfiguration: "experiment: foo\n",
+		DroppedFrames:     2,
+		FrameGaps:         []leto.FrameGap{{First: 10, Last: 11, Time: since, Reason: "busy"}},
+		Crashes:           []leto.ArtemisCrash{{Time: since, ExitCode: -1, Error: "signal: segmentation fault", Restarted: true}},
+		Disk:              &leto.DiskStatus{Free: 1024, Level: leto.DISK_WARNING, VideoSavingStopped: true},
+	})
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, `{"since":"2021-03-04T10:00:00Z","experiment_dir":"foo.0000","yaml_configuration":"experiment: foo\n",`+
+		`"dropped_frames":2,"frame_gaps":[{"first_frame":10,"last_frame":11,"time":"2021-03-04T10:00:00Z","reason":"busy"}],`+
+		`"crashes":[{"time":"2021-03-04T10:00:00Z","exit_code":-1,"error":"signal: segmentation fault","restarted":true}],`+
+		`"disk":{"free":1024,"level":"warning","video_saving_stopped":true}}`)
+
+	resp := leto.Response{}
+	c.Check(s.request(c, "POST", "/status", "", &resp), Equals, http.StatusMethodNotAllowed)
+	c.Check(resp.Error, Equals, "method POST not allowed")
+}
+
+func (s *RESTAPISuite) TestLastExperimentLog(c *C) {
+	resp := leto.Response{}
+	c.Check(s.request(c, "GET", "/last-experiment-log", "", &resp), Equals, http.StatusNotFound)
+	c.Check(resp.Error, Equals, "no experiment was run")
+
+	start := time.Date(2021, 03, 04, 10, 00, 00, 00, time.UTC)
+	s.leto.artemis.lastExperimentLog = &leto.ExperimentLog{
+		Log:           "some log",
+		ExperimentDir: "foo.0000",
+		Start:         start,
+		End:           start.Add(time.Hour),
+		HasError:      true,
+		Crashes:       []leto.ArtemisCrash{{Time: start, ExitCode: 1, Error: "exit status 1"}},
+	}
+	log := map[string]interface{}{}
+	c.Check(s.request(c, "GET", "/last-experiment-log", "", &log), Equals, http.StatusOK)
+	c.Check(log, DeepEquals, map[string]interface{}{
+		"log":                "some log",
+		"stderr":             "",
+		"experiment_dir":     "foo.0000",
+		"start":              "2021-03-04T10:00:00Z",
+		"end":                "2021-03-04T11:00:00Z",
+		"yaml_configuration": "",
+		"has_error":          true,
+		"crashes": []interface{}{map[string]interface{}{
+			"time":      "2021-03-04T10:00:00Z",
+			"exit_code": 1.0,
+			"error":     "exit status 1",
+			"restarted": false,
+		}},
+	})
+}
+
+func (s *RESTAPISuite) TestControlsTheNode(c *C) {
+	resp := leto.Response{}
+	c.Check(s.request(c, "POST", "/stop", "", &resp), Equals, http.StatusConflict)
+	c.Check(resp.Error, Equals, "cannot stop the experiment while the node is idle")
+
+	c.Check(s.request(c, "POST", "/start", `{"yaml_configuration":"camera: ["}`, &resp), Equals, http.StatusBadRequest)
+	c.Check(resp.Error, Matches, "invalid configuration: .*")
+
+	c.Check(s.request(c, "POST", "/start", `{"yaml_configuration":"experiment: foo"}`, &resp), Equals, http.StatusUnprocessableEntity)
+	c.Check(resp.Error, Matches, "incomplete tracking configuration: .*")
+
+	c.Check(s.request(c, "POST", "/start", `{"profile":"none"}`, &resp), Equals, http.StatusNotFound)
+	c.Check(resp.Error, Equals, "unknown profile 'none'")
+
+	c.Check(s.request(c, "POST", "/link", `{"master":"bar","slave":"baz"}`, &resp), Equals, http.StatusBadRequest)
+	c.Check(resp.Error, Equals, "Host foo is neither master (bar) or slave (baz)")
+
+	c.Check(s.request(c, "POST", "/unlink", `{"master":"bar","slave":"foo","foo":1}`, &resp), Equals, http.StatusBadRequest)
+	c.Check(resp.Error, Matches, `invalid request body: json: unknown field "foo"`)
+
+	c.Check(s.request(c, "POST", "/unlink", `{"master":"bar","slave":"foo"}`, &resp), Equals, http.StatusOK)
+	c.Check(resp.Error, Equals, "")
+	c.Check(s.leto.artemis.Status().Master, Equals, "")
+}
+
+func (s *RESTAPISuite) TestMapsErrorsToStatusCodes(c *C) {
+	testdata := []struct {
+		Err  error
+		Code int
+	}{
+		{&invalidRequestError{errors.New("foo")}, http.StatusBadRequest},
+		{&unknownProfileError{Name: "foo"}, http.StatusNotFound},
+		{&configurationError{errors.New("foo")}, http.StatusUnprocessableEntity},
+		{&leto.StateError{Operation: "stop", State: leto.EXPERIMENT_IDLE}, http.StatusConflict},
+		{&refusedError{errors.New("not enough free space")}, http.StatusConflict},
+		{&leto.IncompatibleError{Node: "bar", Version: "v0.3.0"}, http.StatusConflict},
+		{fmt.Errorf("Could not find slave 'bar': %w", &leto.MissingNodesError{Missing: []string{"bar"}}), http.StatusBadGateway},
+		{fmt.Errorf("Could not unlink slave 'bar': %w", &leto.UnreachableError{Node: "bar", Err: errors.New("connection refused")}), http.StatusBadGateway},
+		{&leto.RemoteError{Node: "bar", Method: "Leto.Link", Message: "foo"}, http.StatusBadGateway},
+		{&leto.TimeoutError{Node: "bar", Method: "Leto.Link", Err: context.DeadlineExceeded}, http.StatusGatewayTimeout},
+		{&os.PathError{Op: "open", Path: "/foo", Err: os.ErrPermission}, http.StatusInternalServerError},
+	}
+	for _, d := range testdata {
+		_, err := response(d.Err)
+		c.Assert(err, Not(IsNil))
+		c.Check(err.code, Equals, d.Code, Commentf("error: %s", d.Err))
+	}
+	res, err := response(nil)
+	c.Check(err, IsNil)
+	c.Check(res, Equals, leto.Response{})
+}
+
+func (s *RESTAPISuite) TestServesRPCDebugPage(c *C) {
+	resp, err := http.Get(s.server.URL + rpc.DefaultDebugPath)
+	c.Assert(err, IsNil)
+	defer resp.Body.Close()
+	c.Check(resp.StatusCode, Equals, http.StatusOK)
+	body, err := ioutil.ReadAll(resp.Body)
+	c.Assert(err, IsNil)
+	c.Check(string(body), Matches, "(?s).*Leto.*")
+}
+
+func (s *RESTAPISuite) TestStreamsEvents(c *C) {
+	events := s.leto.artemis.Events()
+	events.Publish(leto.Event{Type: leto.EVENT_EXPERIMENT_STARTED, Experiment: "bar"})
+
+	host, port, err := net.SplitHostPort(strings.TrimPrefix(s.server.URL, "http://"))
+	c.Assert(err, IsNil)
+	node := leto.Node{Name: "foo", Address: host}
+	node.Port, err = strconv.Atoi(port)
+	c.Assert(err, IsNil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	received := make(chan leto.Event, 10)
+	done := make(chan error)
+	since := uint64(0)
+	go func() {
+		done <- leto.NewRPCClient().FollowEvents(ctx, node, &since, func(e leto.Event) {
+			received <- e
+		})
+	}()
+
+	e := <-received
+	c.Check(e.Seq, Equals, uint64(1))
+	c.Check(e.Type, Equals, leto.EVENT_EXPERIMENT_STARTED)
+	c.Check(e.Node, Equals, "foo")
+	c.Check(e.Experiment, Equals, "bar")
+
+	code := 1
+	events.Publish(leto.Event{Type: leto.EVENT_ARTEMIS_EXITED, ExitCode: &code})
+	e = <-received
+	c.Check(e.Seq, Equals, uint64(2))
+	c.Assert(e.ExitCode, Not(IsNil))
+	c.Check(*e.ExitCode, Equals, 1)
+
+	cancel()
+	_, ok := (<-done).(*leto.TimeoutError)
+	c.Check(ok, Equals, true)
+
+	resp := leto.Response{}
+	c.Check(s.request(c, "GET", "/events?since=foo", "", &resp), Equals, http.StatusBadRequest)
+}
@@ -8,19 +8,122 @@ import (
 	"net/rpc"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"time"
 
 	"github.com/formicidae-tracker/leto"
 	"github.com/grandcat/zeroconf"
+	"github.com/jessevdk/go-flags"
 )
 
 type Leto struct {
-	artemis *ArtemisManager
-	logger  *log.Logger
+	options   Options
+	artemis   *ArtemisManager
+	profiles  *ProfileStore
+	schedules *Scheduler
+	logger    *log.Logger
+}
+
+// setResponse reports err in resp.
+func setResponse(resp *leto.Response, err error) {
+	if err != nil {
+		resp.Error = err.Error()
+	} else {
+		resp.Error = ""
+	}
 }
 
 func (l *Leto) StartTracking(args *leto.TrackingConfiguration, resp *leto.Response) error {
-	l.logger.Printf("new start request for experiment '%s'", args.ExperimentName)
-	err := l.artemis.Start(args)
+	setResponse(resp, l.startTracking(args))
+	return nil
+}
+
+func (l *Leto) startTracking(config *leto.TrackingConfiguration) error {
+	l.logger.Printf("new start request for experiment '%s'", config.ExperimentName)
+	return l.artemis.Start(config)
+}
+
+func (l *Leto) StartTrackingProfile(args *leto.ProfileTrackingStart, resp *leto.Response) error {
+	setResponse(resp, l.startTrackingProfile(args))
+	return nil
+}
+
+func (l *Leto) startTrackingProfile(args *leto.ProfileTrackingStart) error {
+	l.logger.Printf("new start request for profile '%s'", args.Profile)
+	config, err := l.profiles.Load(args.Profile)
+	if err != nil {
+		return err
+	}
+	overrides, err := leto.ParseConfiguration([]byte(args.YamlOverrides))
+	if err != nil {
+		return &configurationError{err}
+	}
+	if err := config.Merge(overrides); err != nil {
+		return &configurationError{err}
+	}
+	return l.artemis.Start(config)
+}
+
+func (l *Leto) ListProfiles(args *leto.NoArgs, reply *leto.ProfileList) error {
+	names, err := l.profiles.List()
+	if err != nil {
+		return err
+	}
+	reply.Profiles = make([]leto.Profile, 0, len(names))
+	for _, name := range names {
+		config, err := l.profiles.Load(name)
+		if err != nil {
+			return err
+		}
+		yamlConfig, err := config.Yaml()
+		if err != nil {
+			return err
+		}
+		reply.Profiles = append(reply.Profiles, leto.Profile{Name: name, YamlConfiguration: string(yamlConfig)})
+	}
+	return nil
+}
+
+func (l *Leto) SaveProfile(args *leto.Profile, resp *leto.Response) error {
+	l.logger.Printf("saving profile '%s'", args.Name)
+	config, err := leto.ParseConfiguration([]byte(args.YamlConfiguration))
+	if err == nil {
+		err = l.profiles.Save(args.Name, config)
+	}
+	if err != nil {
+		resp.Error = err.Error()
+	} else {
+		resp.Error = ""
+	}
+	return nil
+}
+
+func (l *Leto) DeleteProfile(args *leto.Profile, resp *leto.Response) error {
+	l.logger.Printf("deleting profile '%s'", args.Name)
+	err := l.profiles.Delete(args.Name)
+	if err != nil {
+		resp.Error = err.Error()
+	} else {
+		resp.Error = ""
+	}
+	return nil
+}
+
+func (l *Leto) ListSchedules(args *leto.NoArgs, reply *leto.ScheduleList) error {
+	reply.Schedules = l.schedules.List()
+	return nil
+}
+
+func (l *Leto) AddSchedule(args *leto.Schedule, reply *leto.Schedule) error {
+	l.logger.Printf("new schedule request")
+	var err error
+	*reply, err = l.schedules.Add(*args)
+	return err
+}
+
+func (l *Leto) CancelSchedule(args *leto.Schedule, resp *leto.Response) error {
+	l.logger.Printf("cancelling schedule '%s'", args.ID)
+	err := l.schedules.Cancel(args.ID)
 	if err != nil {
 		resp.Error = err.Error()
 	} else {
@@ -31,7 +134,13 @@ func (l *Leto) StartTracking(args *leto.TrackingConfiguration, resp *leto.Respon
 
 func (l *Leto) StopTracking(args *leto.NoArgs, resp *leto.Response) error {
 	l.logger.Printf("new stop request")
-	err := l.artemis.Stop()
+	setResponse(resp, l.artemis.Stop())
+	return nil
+}
+
+func (l *Leto) UpdateTracking(args *leto.TrackingConfiguration, resp *leto.Response) error {
+	l.logger.Printf("new update request")
+	err := l.artemis.Update(args)
 	if err != nil {
 		resp.Error = err.Error()
 	} else {
@@ -45,47 +154,72 @@ func (l *Leto) Status(args *leto.NoArgs, resp *leto.Status) error {
 	return nil
 }
 
+func (l *Leto) Version(args *leto.NoArgs, reply *string) error {
+	*reply = leto.LETO_VERSION
+	return nil
+}
+
+func (l *Leto) Capabilities(args *leto.NoArgs, reply *leto.Capabilities) error {
+	*reply = leto.LocalCapabilities()
+	return nil
+}
+
+func (l *Leto) DefaultConfiguration(args *leto.NoArgs, reply *leto.DefaultConfiguration) error {
+	config, modTime, loadErr := leto.LoadDefaultConfigFile(l.options.SiteConfigPath)
+	yamlConfig, err := config.Yaml()
+	if err != nil {
+		return err
+	}
+	reply.YamlConfiguration = string(yamlConfig)
+	reply.Path = l.options.SiteConfigPath
+	reply.ModTime = modTime
+	reply.Error = ""
+	if loadErr != nil {
+		reply.Error = loadErr.Error()
+	}
+	return nil
+}
+
 func (l *Leto) LastExperimentLog(args *leto.NoArgs, reply **leto.ExperimentLog) error {
 	*reply = l.artemis.LastExperimentLog()
 	return nil
 }
 
 func (l *Leto) Link(args *leto.Link, resp *leto.Response) error {
-	var err error = nil
-	defer func() {
-		resp.Error = ""
-		if err != nil {
-			resp.Error = err.Error()
-		}
-	}()
-	host, err := os.Hostname()
-	if err != nil {
-		return nil
-	}
+	setResponse(resp, l.link(args))
+	return nil
+}
 
+// checkLinkedHost returns an error if this node is not part of args.
+func (l *Leto) checkLinkedHost(args *leto.Link) error {
+	host := l.options.Name
 	if args.Master != host && args.Slave != host {
-		err = fmt.Errorf("Host %s is neither master (%s) or slave (%s)", host, args.Master, args.Slave)
-		return nil
+		return &invalidRequestError{fmt.Errorf("Host %s is neither master (%s) or slave (%s)", host, args.Master, args.Slave)}
 	}
+	return nil
+}
 
-	if args.Slave == host {
-		err = l.artemis.SetMaster(args.Master)
-		return nil
+func (l *Leto) link(args *leto.Link) error {
+	if err := l.checkLinkedHost(args); err != nil {
+		return err
 	}
-	nodes, err := leto.NewNodeLister().ListNodes()
-	if err != nil {
-		return fmt.Errorf("Could not list local nodes: %s", err)
+
+	if args.Slave == l.options.Name {
+		return l.artemis.SetMaster(args.Master)
 	}
-	slave, ok := nodes[args.Slave]
-	if ok == false {
-		return fmt.Errorf("Could not find slave '%s'", args.Slave)
+	nodes, err := leto.NewNodeLister().ListExpectedNodes([]string{args.Slave}, l.options.DiscoveryTimeout)
+	if err != nil {
+		return fmt.Errorf("Could not find slave '%s': %w", args.Slave, err)
 	}
+	slave := nodes[args.Slave]
 
-	respSlave := leto.Response{}
-	err = slave.RunMethod("Leto.Link", args, &respSlave)
-	if err == nil {
-		err = respSlave.ToError()
+	ctx, cancel := context.WithTimeout(context.Background(), l.options.RPCTimeout)
+	defer cancel()
+	err = slave.CheckCompatible(ctx, l.options.requiredSlaveFeatures()...)
+	if err != nil {
+		return err
 	}
+	err = slave.Call(ctx, "Leto.Link", args, &leto.Response{})
 	if err != nil {
 		return err
 	}
@@ -94,82 +228,111 @@ func (l *Leto) Link(args *leto.Link, resp *leto.Response) error {
 }
 
 func (l *Leto) Unlink(args *leto.Link, resp *leto.Response) error {
-	var err error = nil
-	defer func() {
-		resp.Error = ""
-		if err != nil {
-			resp.Error = err.Error()
-		}
-	}()
-	host, err := os.Hostname()
-	if err != nil {
-		return nil
-	}
+	setResponse(resp, l.unlink(args))
+	return nil
+}
 
-	if args.Master != host && args.Slave != host {
-		err = fmt.Errorf("Host %s is neither master (%s) or slave (%s)", host, args.Master, args.Slave)
-		return nil
+func (l *Leto) unlink(args *leto.Link) error {
+	if err := l.checkLinkedHost(args); err != nil {
+		return err
 	}
 
-	if args.Slave == host {
-		err = l.artemis.SetMaster("")
-		return nil
+	if args.Slave == l.options.Name {
+		return l.artemis.SetMaster("")
 	}
 
-	nodes, err := leto.NewNodeLister().ListNodes()
+	nodes, err := leto.NewNodeLister().ListExpectedNodes([]string{args.Slave}, l.options.DiscoveryTimeout)
 	if err != nil {
-		return fmt.Errorf("Could not list local nodes: %s", err)
-	}
-	slave, ok := nodes[args.Slave]
-	if ok == false {
-		return fmt.Errorf("Could not find slave '%s'", args.Slave)
+		return fmt.Errorf("Could not find slave '%s': %w", args.Slave, err)
 	}
+	slave := nodes[args.Slave]
 
-	respSlave := leto.Response{}
-	err = slave.RunMethod("Leto.Unlink", args, &respSlave)
-	if err == nil {
-		err = respSlave.ToError()
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), l.options.RPCTimeout)
+	defer cancel()
+	err = slave.Call(ctx, "Leto.Unlink", args, &leto.Response{})
 	if err != nil {
-		return fmt.Errorf("Could not unlink slave '%s': %s", args.Slave, err)
+		return fmt.Errorf("Could not unlink slave '%s': %w", args.Slave, err)
 	}
 
 	return l.artemis.RemoveSlave(args.Slave)
 }
 
 func Execute() error {
-	if len(os.Args) > 1 && os.Args[1] == "--version" {
+	opts := Options{}
+	if _, err := flags.Parse(&opts); err != nil {
+		if ferr, ok := err.(*flags.Error); ok == true && ferr.Type == flags.ErrHelp {
+			return nil
+		}
+		return err
+	}
+
+	if opts.Version == true {
 		fmt.Printf("leto %s\n", leto.LETO_VERSION)
 		return nil
 	}
 
-	host, err := os.Hostname()
-	if err != nil {
+	if err := opts.Resolve(); err != nil {
+		return err
+	}
+	if err := setLogFormat(opts.LogFormat); err != nil {
 		return err
 	}
+	leto.DefaultNodeCacheTTL = opts.NodeCacheTTL
 
-	l := &Leto{}
-	l.artemis, err = NewArtemisManager()
+	l := &Leto{
+		options:  opts,
+		profiles: NewProfileStore(filepath.Join(opts.StateDir(), "profiles")),
+	}
+	var err error
+	l.artemis, err = NewArtemisManager(opts)
 	if err != nil {
 		return err
 	}
 
+	l.logger = newLogger("[rpc] ")
+
+	if _, _, err := leto.LoadDefaultConfigFile(opts.SiteConfigPath); err != nil {
+		l.logger.Printf("experiments cannot be started: %s", err)
+	}
+
 	l.artemis.LoadFromPersistentFile()
 
-	l.logger = log.New(os.Stderr, "[rpc] ", 0)
+	l.schedules, err = NewScheduler(filepath.Join(opts.StateDir(), "schedules.yml"), l.artemis, l.profiles, l.artemis.Events())
+	if err != nil {
+		return err
+	}
+	go l.schedules.Run()
+
 	rpcRouter := rpc.NewServer()
 	rpcRouter.Register(l)
 	rpcRouter.HandleHTTP(rpc.DefaultRPCPath, rpc.DefaultDebugPath)
+	token, err := opts.Token()
+	if err != nil {
+		return err
+	}
+	tlsConfig, err := opts.ServerTLSConfig()
+	if err != nil {
+		return err
+	}
+	// calls to slaves use the same credentials
+	if err := opts.ConfigureClient(leto.DefaultRPCClient); err != nil {
+		return err
+	}
 	rpcServer := http.Server{
-		Addr:    fmt.Sprintf(":%d", leto.LETO_PORT),
-		Handler: rpcRouter,
+		Addr:      fmt.Sprintf(":%d", opts.Port),
+		Handler:   leto.RequireToken(newHTTPHandler(l, rpcRouter), token),
+		TLSConfig: tlsConfig,
 	}
 
+	// event streams never become idle, they must be ended to shutdown.
+	rpcServer.RegisterOnShutdown(l.artemis.Events().Close)
+
 	idleConnections := make(chan struct{})
 	go func() {
 		sigint := make(chan os.Signal, 1)
 		signal.Notify(sigint, os.Interrupt)
 		<-sigint
+		l.schedules.Close()
 		if err := rpcServer.Shutdown(context.Background()); err != nil {
 			l.logger.Printf("could not shutdown: %s", err)
 		}
@@ -177,19 +340,41 @@ func Execute() error {
 	}()
 
 	go func() {
-		server, err := zeroconf.Register("leto."+host, "_leto._tcp", "local.", leto.LETO_PORT, nil, nil)
+		server, err := zeroconf.Register("leto."+opts.Name, "_leto._tcp", "local.", opts.Port, l.artemis.AdvertisedNode().TXTRecords(), nil)
 		if err != nil {
 			log.Printf("[avahi] register error: %s", err)
 			return
 		}
 		sigint := make(chan os.Signal, 1)
 		signal.Notify(sigint, os.Interrupt)
-		<-sigint
-		server.Shutdown()
+		// the site configuration is not watched, its check is only
+		// advertised again periodically.
+		siteConfigCheck := time.NewTicker(SITE_CONFIGURATION_CHECK_PERIOD)
+		defer siteConfigCheck.Stop()
+		advertised := l.artemis.AdvertisedNode()
+		for {
+			select {
+			case <-sigint:
+				server.Shutdown()
+				return
+			case <-l.artemis.StateChanged():
+			case <-siteConfigCheck.C:
+				if l.artemis.AdvertisedNode().Misconfigured == advertised.Misconfigured {
+					continue
+				}
+			}
+			advertised = l.artemis.AdvertisedNode()
+			server.SetText(advertised.TXTRecords())
+		}
 	}()
 
 	l.logger.Printf("listening on %s", rpcServer.Addr)
-	if err := rpcServer.ListenAndServe(); err != http.ErrServerClosed {
+	if tlsConfig != nil {
+		err = rpcServer.ListenAndServeTLS("", "")
+	} else {
+		err = rpcServer.ListenAndServe()
+	}
+	if err != http.ErrServerClosed {
 		return err
 	}
 
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Metrics are the counters and gauges of the tracking pipeline. They
+// are exposed in the Prometheus text format on /metrics.
+type Metrics struct {
+	mx               sync.Mutex
+	framesReceived   map[string]uint64
+	mergerTimeouts   uint64
+	dispatchDropped  map[string]uint64
+	fileBytesWritten uint64
+	broadcastClients int
+	ffmpegRestarts   uint64
+	clockOffsets     map[string]float64
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		framesReceived:  make(map[string]uint64),
+		dispatchDropped: make(map[string]uint64),
+		clockOffsets:    make(map[string]float64),
+	}
+}
+
+// metrics are the Metrics of the daemon.
+var metrics = NewMetrics()
+
+// ResetProducers forgets the series of the producers of a previous
+// experiment, as producer UUIDs change with each experiment.
+func (m *Metrics) ResetProducers() {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.framesReceived = make(map[string]uint64)
+	m.clockOffsets = make(map[string]float64)
+}
+
+func (m *Metrics) FrameReceived(producer string) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.framesReceived[producer] += 1
+}
+
+func (m *Metrics) MergerTimeout() {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.mergerTimeouts += 1
+}
+
+func (m *Metrics) DispatchDropped(output string) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.dispatchDropped[output] += 1
+}
+
+func (m *Metrics) FileBytesWritten(n int) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.fileBytesWritten += uint64(n)
+}
+
+func (m *Metrics) AddBroadcastClients(delta int) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.broadcastClients += delta
+}
+
+func (m *Metrics) FFMpegRestarted() {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.ffmpegRestarts += 1
+}
+
+// SetClockOffset sets the clock offset of producer, in microseconds.
+func (m *Metrics) SetClockOffset(producer string, offsetUS float64) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.clockOffsets[producer] = offsetUS
+}
+
+func escapeLabelValue(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+type metricFamily struct {
+	name, help, kind, label string
+	values                  map[string]float64
+}
+
+func (f metricFamily) writeTo(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.kind)
+	if len(f.label) == 0 {
+		fmt.Fprintf(w, "%s %s\n", f.name, formatValue(f.values[""]))
+		return
+	}
+	keys := make([]string, 0, len(f.values))
+	for k := range f.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s{%s=\"%s\"} %s\n", f.name, f.label, escapeLabelValue(k), formatValue(f.values[k]))
+	}
+}
+
+func uintValues(values map[string]uint64) map[string]float64 {
+	res := make(map[string]float64, len(values))
+	for k, v := range values {
+		res[k] = float64(v)
+	}
+	return res
+}
+
+// Write writes all metrics in the Prometheus text format.
+func (m *Metrics) Write(w io.Writer) {
+	m.mx.Lock()
+	offsets := make(map[string]float64, len(m.clockOffsets))
+	for k, v := range m.clockOffsets {
+		offsets[k] = v * 1.0e-6
+	}
+	families := []metricFamily{
+		{
+			name: "leto_frames_received_total", kind: "counter", label: "producer",
+			help:   "Frame readouts received from artemis trackers.",
+			values: uintValues(m.framesReceived),
+		},
+		{
+			name: "leto_merger_timeouts_total", kind: "counter",
+			help:   "Frames marked as timed out by the frame readout merger.",
+			values: map[string]float64{"": float64(m.mergerTimeouts)},
+		},
+		{
+			name: "leto_dispatch_dropped_frames_total", kind: "counter", label: "output",
+			help:   "Frame readouts dropped because an output was not ready.",
+			values: uintValues(m.dispatchDropped),
+		},
+		{
+			name: "leto_file_written_bytes_total", kind: "counter",
+			help:   "Bytes written to hermes tracking files.",
+			values: map[string]float64{"": float64(m.fileBytesWritten)},
+		},
+		{
+			name: "leto_broadcast_clients", kind: "gauge",
+			help:   "Clients connected to the frame readout broadcast.",
+			values: map[string]float64{"": float64(m.broadcastClients)},
+		},
+		{
+			name: "leto_ffmpeg_restarts_total", kind: "counter",
+			help:   "Restarts of the ffmpeg tasks, including for new video segments.",
+			values: map[string]float64{"": float64(m.ffmpegRestarts)},
+		},
+		{
+			name: "leto_slave_clock_offset_seconds", kind: "gauge", label: "producer",
+			help:   "Estimated clock offset of slave trackers to the master.",
+			values: offsets,
+		},
+	}
+	m.mx.Unlock()
+
+	for _, f := range families {
+		f.writeTo(w)
+	}
+}
+
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.Write(w)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"net/http/httptest"
+
+	. "gopkg.in/check.v1"
+)
+
+type MetricsSuite struct{}
+
+var _ = Suite(&MetricsSuite{})
+
+func (s *MetricsSuite) TestPrometheusFormat(c *C) {
+	m := NewMetrics()
+	for i := 0; i < 3; i++ {
+		m.FrameReceived("master")
+	}
+	m.FrameReceived("slave")
+	m.MergerTimeout()
+	m.DispatchDropped("file")
+	m.DispatchDropped("broadcast")
+	m.DispatchDropped("broadcast")
+	m.FileBytesWritten(1234567)
+	m.AddBroadcastClients(2)
+	m.AddBroadcastClients(-1)
+	m.FFMpegRestarted()
+	m.SetClockOffset("slave", -1500)
+	m.SetClockOffset(`"strange"`, 0)
+
+	buf := bytes.NewBuffer(nil)
+	m.Write(buf)
+	c.Check(buf.String(), Equals, `# HELP leto_frames_received_total Frame readouts received from artemis trackers.
+# TYPE leto_frames_received_total counter
+leto_frames_received_total{producer="master"} 3
+leto_frames_received_total{producer="slave"} 1
+# HELP leto_merger_timeouts_total Frames marked as timed out by the frame readout merger.
+# TYPE leto_merger_timeouts_total counter
+leto_merger_timeouts_total 1
+# HELP leto_dispatch_dropped_frames_total Frame readouts dropped because an output was not ready.
+# TYPE leto_dispatch_dropped_frames_total counter
+leto_dispatch_dropped_frames_total{output="broadcast"} 2
+leto_dispatch_dropped_frames_total{output="file"} 1
+# HELP leto_file_written_bytes_total Bytes written to hermes tracking files.
+# TYPE leto_file_written_bytes_total counter
+leto_file_written_bytes_total 1234567
+# HELP leto_broadcast_clients Clients connected to the frame readout broadcast.
+# TYPE leto_broadcast_clients gauge
+leto_broadcast_clients 1
+# HELP leto_ffmpeg_restarts_total Restarts of the ffmpeg tasks, including for new video segments.
+# TYPE leto_ffmpeg_restarts_total counter
+leto_ffmpeg_restarts_total 1
+# HELP leto_slave_clock_offset_seconds Estimated clock offset of slave trackers to the master.
+# TYPE leto_slave_clock_offset_seconds gauge
+leto_slave_clock_offset_seconds{producer="\"strange\""} 0
+leto_slave_clock_offset_seconds{producer="slave"} -0.0015
+`)
+
+	m.ResetProducers()
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	c.Check(rec.Header().Get("Content-Type"), Equals, "text/plain; version=0.0.4")
+	c.Check(rec.Body.String(), Not(Matches), `(?s).*producer=.*`)
+}
@@ -5,7 +5,6 @@ import (
 	"io/ioutil"
 	"os"
 
-	"github.com/adrg/xdg"
 	"gopkg.in/yaml.v2"
 )
 
@@ -14,21 +13,12 @@ type NodeConfiguration struct {
 	Slaves []string `yaml:"slaves"`
 }
 
-func localConfigPath() (string, error) {
-	return xdg.ConfigFile("FORmicidae Tracker/leto.yml")
-}
-
 var defaultNodeConfiguration NodeConfiguration = NodeConfiguration{
 	Master: "",
 	Slaves: nil,
 }
 
-func GetNodeConfiguration() NodeConfiguration {
-	confPath, err := localConfigPath()
-	if err != nil {
-		return defaultNodeConfiguration
-	}
-
+func GetNodeConfiguration(confPath string) NodeConfiguration {
 	conf, err := os.Open(confPath)
 	if err != nil {
 		return defaultNodeConfiguration
@@ -49,11 +39,7 @@ func GetNodeConfiguration() NodeConfiguration {
 	return res
 }
 
-func (c NodeConfiguration) Save() {
-	confPath, err := localConfigPath()
-	if err != nil {
-		return
-	}
+func (c NodeConfiguration) Save(confPath string) {
 	data, err := yaml.Marshal(c)
 	if err != nil {
 		return
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/adrg/xdg"
+	"github.com/formicidae-tracker/leto"
+	"github.com/jessevdk/go-flags"
+)
+
+// Options are the command line options of the leto daemon. Each of
+// them can also be set from a LETO_* environment variable, which
+// allows to run several isolated instances on a single host.
+type Options struct {
+	Version          bool          `long:"version" description:"print version and exit"`
+	Name             string        `long:"name" env:"LETO_NAME" description:"node name advertised on the network (default: hostname)"`
+	Port             int           `short:"p" long:"port" env:"LETO_PORT" description:"port to listen to for RPC requests" default:"4000"`
+	ArtemisInPort    int           `long:"artemis-in-port" env:"LETO_ARTEMIS_IN_PORT" description:"port receiving frame readouts from the local and slaves' artemis" default:"4001"`
+	ArtemisOutPort   int           `long:"artemis-out-port" env:"LETO_ARTEMIS_OUT_PORT" description:"port broadcasting merged frame readouts" default:"4002"`
+	DataDir          string        `long:"data-dir" env:"LETO_DATA_DIR" description:"root directory for experiment data and daemon state (default: $XDG_DATA_HOME)"`
+	SiteConfigPath   string        `long:"site-config" env:"LETO_SITE_CONFIG" description:"path to the site tracking configuration" default:"/etc/default/leto.yml"`
+	NodeConfigPath   string        `long:"node-config" env:"LETO_NODE_CONFIG" description:"path to the node master/slave configuration (default: $XDG_CONFIG_HOME/FORmicidae Tracker/leto.yml)"`
+	DiscoveryTimeout time.Duration `long:"discovery-timeout" env:"LETO_DISCOVERY_TIMEOUT" description:"maximal time to look for slaves or master on the network" default:"5s"`
+	NodeCacheTTL     time.Duration `long:"node-cache-ttl" env:"LETO_NODE_CACHE_TTL" description:"duration the nodes found on the network are cached without looking for them again" default:"5s"`
+	RPCTimeout       time.Duration `long:"rpc-timeout" env:"LETO_RPC_TIMEOUT" description:"maximal duration of a request to a slave" default:"20s"`
+	ArtemisPath      string        `long:"artemis" env:"LETO_ARTEMIS" description:"artemis executable to use" default:"artemis"`
+	FFMpegPath       string        `long:"ffmpeg" env:"LETO_FFMPEG" description:"ffmpeg executable to use" default:"ffmpeg"`
+	MaxRestarts      int           `long:"artemis-max-restarts" env:"LETO_ARTEMIS_MAX_RESTARTS" description:"maximal number of restarts of a crashed artemis within the restart window, 0 disables restarts" default:"5"`
+	RestartBackoff   time.Duration `long:"artemis-restart-backoff" env:"LETO_ARTEMIS_RESTART_BACKOFF" description:"delay before restarting a crashed artemis, doubled for each restart within the restart window" default:"10s"`
+	RestartWindow    time.Duration `long:"artemis-restart-window" env:"LETO_ARTEMIS_RESTART_WINDOW" description:"period over which artemis restarts are counted" default:"1h"`
+	DiskWarning      float64       `long:"disk-warning" env:"LETO_DISK_WARNING" description:"free space in GiB of the experiment filesystem under which a warning is reported" default:"20"`
+	DiskCritical     float64       `long:"disk-critical" env:"LETO_DISK_CRITICAL" description:"free space in GiB of the experiment filesystem which must remain once an experiment is done, and under which the disk space is critical" default:"5"`
+	DiskDuration     time.Duration `long:"disk-planned-duration" env:"LETO_DISK_PLANNED_DURATION" description:"duration assumed for experiments without planned end when checking the free space before starting them, 0 disables the check for them" default:"24h"`
+	DiskStopVideo    bool          `long:"disk-critical-stop-video" env:"LETO_DISK_CRITICAL_STOP_VIDEO" description:"stops saving video once the disk space is critical, to keep it for tracking data"`
+	LogFormat        string        `long:"log-format" env:"LETO_LOG_FORMAT" description:"format of log lines, 'timestamp' prefixes them with the local date and time" choice:"plain" choice:"timestamp" default:"plain"`
+
+	leto.RPCCredentials
+}
+
+// DefaultOptions returns the Options used when no flag or environment
+// variable is set. The values come from the default tags of Options,
+// which are also the ones go-flags uses when parsing the command line.
+func DefaultOptions() Options {
+	res := Options{}
+	parser := flags.NewParser(&res, flags.None)
+	ignoreEnvironment(parser.Command.Group)
+	if _, err := parser.ParseArgs([]string{}); err != nil {
+		panic(fmt.Sprintf("invalid Options default tags: %s", err))
+	}
+	return res
+}
+
+// ignoreEnvironment stops options of g and its subgroups from
+// defaulting to environment variables.
+func ignoreEnvironment(g *flags.Group) {
+	for _, o := range g.Options() {
+		o.EnvDefaultKey = ""
+	}
+	for _, sub := range g.Groups() {
+		ignoreEnvironment(sub)
+	}
+}
+
+// Resolve fills values that defaults to XDG directories or to the
+// host name, and checks ports.
+func (o *Options) Resolve() error {
+	if len(o.Name) == 0 {
+		var err error
+		o.Name, err = os.Hostname()
+		if err != nil {
+			return err
+		}
+	}
+	if len(o.DataDir) == 0 {
+		o.DataDir = xdg.DataHome
+	}
+	if len(o.NodeConfigPath) == 0 {
+		var err error
+		o.NodeConfigPath, err = xdg.ConfigFile("FORmicidae Tracker/leto.yml")
+		if err != nil {
+			return err
+		}
+	}
+	if o.DiskCritical < 0 || o.DiskWarning < o.DiskCritical {
+		return fmt.Errorf("invalid disk thresholds: warning %g GiB, critical %g GiB", o.DiskWarning, o.DiskCritical)
+	}
+	ports := map[int]string{}
+	for _, p := range []struct {
+		name string
+		port int
+	}{
+		{"port", o.Port},
+		{"artemis-in-port", o.ArtemisInPort},
+		{"artemis-out-port", o.ArtemisOutPort},
+	} {
+		if p.port <= 0 || p.port > 65535 {
+			return fmt.Errorf("invalid %s %d", p.name, p.port)
+		}
+		if other, ok := ports[p.port]; ok == true {
+			return fmt.Errorf("%s and %s cannot both be %d", other, p.name, p.port)
+		}
+		ports[p.port] = p.name
+	}
+	return nil
+}
+
+// Node returns how this instance is advertised on the network.
+func (o Options) Node() leto.Node {
+	return leto.Node{
+		Name:           o.Name,
+		Port:           o.Port,
+		ArtemisInPort:  o.ArtemisInPort,
+		ArtemisOutPort: o.ArtemisOutPort,
+	}
+}
+
+// requiredSlaveFeatures returns the features a slave must support to
+// track for this node as a master.
+func (o Options) requiredSlaveFeatures() []string {
+	res := []string{leto.FEATURE_SLAVE_TRACKING}
+	if o.ArtemisInPort != leto.ARTEMIS_IN_PORT {
+		res = append(res, leto.FEATURE_NODE_PORTS)
+	}
+	return res
+}
+
+// RestartPolicy returns the policy to restart a crashed artemis.
+func (o Options) RestartPolicy() RestartPolicy {
+	return RestartPolicy{
+		MaxRestarts: o.MaxRestarts,
+		Backoff:     o.RestartBackoff,
+		Window:      o.RestartWindow,
+	}
+}
+
+// DiskThresholds returns the thresholds of free space in bytes for
+// the disk space levels.
+func (o Options) DiskThresholds() DiskThresholds {
+	return DiskThresholds{
+		Warning:  uint64(o.DiskWarning * GiB),
+		Critical: uint64(o.DiskCritical * GiB),
+	}
+}
+
+func (o Options) ExperimentsDir() string {
+	return filepath.Join(o.DataDir, "fort-experiments")
+}
+
+func (o Options) StateDir() string {
+	return filepath.Join(o.DataDir, "fort/leto")
+}
+
+var logFlags int = 0
+
+func setLogFormat(format string) error {
+	switch format {
+	case "plain":
+		logFlags = 0
+	case "timestamp":
+		logFlags = log.LstdFlags
+	default:
+		return fmt.Errorf("unknown log format '%s'", format)
+	}
+	log.SetFlags(logFlags)
+	return nil
+}
+
+func newLogger(prefix string) *log.Logger {
+	return log.New(os.Stderr, prefix, logFlags)
+}
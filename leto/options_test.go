@@ -0,0 +1,29 @@
+package main
+
+import (
+	"os"
+
+	"github.com/formicidae-tracker/leto"
+	. "gopkg.in/check.v1"
+)
+
+type OptionsSuite struct{}
+
+var _ = Suite(&OptionsSuite{})
+
+func (s *OptionsSuite) TestDefaultsMatchTheLibrary(c *C) {
+	opts := DefaultOptions()
+	c.Check(opts.Port, Equals, leto.LETO_PORT)
+	c.Check(opts.ArtemisInPort, Equals, leto.ARTEMIS_IN_PORT)
+	c.Check(opts.ArtemisOutPort, Equals, leto.ARTEMIS_OUT_PORT)
+	c.Check(opts.DiscoveryTimeout, Equals, leto.NODE_DISCOVERY_TIMEOUT)
+	c.Check(opts.NodeCacheTTL, Equals, leto.NODE_CACHE_TTL)
+	c.Check(opts.SiteConfigPath, Equals, leto.DEFAULT_CONFIG_PATH)
+	c.Check(opts.LogFormat, Equals, "plain")
+}
+
+func (s *OptionsSuite) TestDefaultsIgnoreTheEnvironment(c *C) {
+	defer os.Unsetenv("LETO_PORT")
+	c.Assert(os.Setenv("LETO_PORT", "5000"), IsNil)
+	c.Check(DefaultOptions().Port, Equals, leto.LETO_PORT)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/formicidae-tracker/leto"
+)
+
+// ProfileStore persists named tracking configurations as YAML files
+// in a directory.
+type ProfileStore struct {
+	mx  sync.Mutex
+	dir string
+}
+
+func NewProfileStore(dir string) *ProfileStore {
+	return &ProfileStore{dir: dir}
+}
+
+var profileNameRx = regexp.MustCompile(`\A[A-Za-z0-9][A-Za-z0-9._\-]*\z`)
+
+func (s *ProfileStore) profilePath(name string) (string, error) {
+	if profileNameRx.MatchString(name) == false {
+		return "", fmt.Errorf("invalid profile name '%s'", name)
+	}
+	return filepath.Join(s.dir, name+".yml"), nil
+}
+
+func (s *ProfileStore) List() ([]string, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.yml"))
+	if err != nil {
+		return nil, err
+	}
+	res := make([]string, 0, len(files))
+	for _, f := range files {
+		res = append(res, strings.TrimSuffix(filepath.Base(f), ".yml"))
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+func (s *ProfileStore) Load(name string) (*leto.TrackingConfiguration, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	fpath, err := s.profilePath(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(fpath); os.IsNotExist(err) {
+		return nil, &unknownProfileError{Name: name}
+	}
+	return leto.ReadConfiguration(fpath)
+}
+
+func (s *ProfileStore) Save(name string, config *leto.TrackingConfiguration) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	fpath, err := s.profilePath(name)
+	if err != nil {
+		return err
+	}
+	// load balancing is always computed by the master
+	toSave := *config
+	toSave.Loads = nil
+	if err := toSave.Validate().ToError(); err != nil {
+		return fmt.Errorf("invalid profile '%s': %s", name, err)
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	data, err := toSave.Yaml()
+	if err != nil {
+		return err
+	}
+	tmpPath := fpath + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("Could not write profile '%s': %s", name, err)
+	}
+	return os.Rename(tmpPath, fpath)
+}
+
+func (s *ProfileStore) Delete(name string) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	fpath, err := s.profilePath(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(fpath); err != nil {
+		if os.IsNotExist(err) == true {
+			return &unknownProfileError{Name: name}
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/formicidae-tracker/leto"
+	. "gopkg.in/check.v1"
+)
+
+type ProfileStoreSuite struct {
+	tmpDir string
+	store  *ProfileStore
+}
+
+var _ = Suite(&ProfileStoreSuite{})
+
+func (s *ProfileStoreSuite) SetUpTest(c *C) {
+	var err error
+	s.tmpDir, err = ioutil.TempDir("", "leto-profile-tests")
+	c.Assert(err, IsNil)
+	s.store = NewProfileStore(s.tmpDir)
+}
+
+func (s *ProfileStoreSuite) TearDownTest(c *C) {
+	c.Check(os.RemoveAll(s.tmpDir), IsNil)
+}
+
+func (s *ProfileStoreSuite) TestSaveLoadDelete(c *C) {
+	config := &leto.TrackingConfiguration{}
+	config.Camera.FPS = new(float64)
+	*config.Camera.FPS = 2.0
+	config.Loads = &leto.LoadBalancing{SelfUUID: "foo"}
+
+	c.Assert(s.store.Save("calibration-2fps", config), IsNil)
+	c.Assert(s.store.Save("colony-36h11-8fps", &leto.TrackingConfiguration{}), IsNil)
+
+	names, err := s.store.List()
+	c.Check(err, IsNil)
+	c.Check(names, DeepEquals, []string{"calibration-2fps", "colony-36h11-8fps"})
+
+	loaded, err := s.store.Load("calibration-2fps")
+	c.Assert(err, IsNil)
+	c.Check(*loaded.Camera.FPS, Equals, 2.0)
+	c.Check(loaded.Loads, IsNil)
+
+	c.Check(s.store.Delete("calibration-2fps"), IsNil)
+	_, err = s.store.Load("calibration-2fps")
+	c.Check(err, ErrorMatches, `unknown profile 'calibration-2fps'`)
+	c.Check(s.store.Delete("calibration-2fps"), ErrorMatches, `unknown profile 'calibration-2fps'`)
+}
+
+func (s *ProfileStoreSuite) TestRejectsInvalidProfiles(c *C) {
+	c.Check(s.store.Save("../escape", &leto.TrackingConfiguration{}), ErrorMatches, `invalid profile name '../escape'`)
+	c.Check(s.store.Save("", &leto.TrackingConfiguration{}), ErrorMatches, `invalid profile name ''`)
+
+	config := &leto.TrackingConfiguration{}
+	config.Camera.FPS = new(float64)
+	*config.Camera.FPS = -3.0
+	c.Check(s.store.Save("negative", config), ErrorMatches, `invalid profile 'negative': camera.fps: -3 must be strictly positive`)
+}
@@ -0,0 +1,32 @@
+package main
+
+import "fmt"
+
+// invalidRequestError is returned for requests which cannot succeed
+// whatever the node state is, like linking an unrelated node.
+type invalidRequestError struct {
+	error
+}
+
+// refusedError is returned when the node refuses a request because
+// of its configuration or resources, like starting an experiment
+// without enough free space.
+type refusedError struct {
+	error
+}
+
+// configurationError is returned when a requested tracking
+// configuration cannot be parsed, merged or is invalid.
+type configurationError struct {
+	error
+}
+
+// unknownProfileError is returned for profiles not in the
+// ProfileStore.
+type unknownProfileError struct {
+	Name string
+}
+
+func (e *unknownProfileError) Error() string {
+	return fmt.Sprintf("unknown profile '%s'", e.Name)
+}
@@ -0,0 +1,31 @@
+package main
+
+import "time"
+
+// RestartPolicy decides if and when artemis is restarted after a
+// crash. At most MaxRestarts restarts are made within any Window,
+// the n-th one being delayed by Backoff*2^n.
+type RestartPolicy struct {
+	MaxRestarts int
+	Backoff     time.Duration
+	Window      time.Duration
+}
+
+// Delay returns the delay before restarting artemis after a crash at
+// now, given the times of the previous restarts, or false if it
+// should not be restarted.
+func (p RestartPolicy) Delay(restarts []time.Time, now time.Time) (time.Duration, bool) {
+	if p.MaxRestarts <= 0 {
+		return 0, false
+	}
+	n := 0
+	for _, t := range restarts {
+		if now.Sub(t) < p.Window {
+			n += 1
+		}
+	}
+	if n >= p.MaxRestarts {
+		return 0, false
+	}
+	return p.Backoff << uint(n), true
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type RestartPolicySuite struct{}
+
+var _ = Suite(&RestartPolicySuite{})
+
+func (s *RestartPolicySuite) TestDelay(c *C) {
+	p := RestartPolicy{MaxRestarts: 3, Backoff: 10 * time.Second, Window: time.Hour}
+	now := time.Date(2021, 3, 4, 10, 0, 0, 0, time.UTC)
+	ago := func(d time.Duration) time.Time { return now.Add(-d) }
+
+	testdata := []struct {
+		Restarts []time.Time
+		Delay    time.Duration
+		Restart  bool
+	}{
+		{nil, 10 * time.Second, true},
+		{[]time.Time{ago(time.Minute)}, 20 * time.Second, true},
+		{[]time.Time{ago(2 * time.Minute), ago(time.Minute)}, 40 * time.Second, true},
+		{[]time.Time{ago(3 * time.Minute), ago(2 * time.Minute), ago(time.Minute)}, 0, false},
+		// restarts out of the window are forgotten
+		{[]time.Time{ago(2 * time.Hour), ago(90 * time.Minute), ago(time.Minute)}, 20 * time.Second, true},
+	}
+
+	for _, d := range testdata {
+		delay, ok := p.Delay(d.Restarts, now)
+		c.Check(ok, Equals, d.Restart, Commentf("restarts: %v", d.Restarts))
+		c.Check(delay, Equals, d.Delay, Commentf("restarts: %v", d.Restarts))
+	}
+
+	p.MaxRestarts = 0
+	_, ok := p.Delay(nil, now)
+	c.Check(ok, Equals, false)
+}
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/formicidae-tracker/leto"
+	"github.com/google/uuid"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// SCHEDULER_MAX_SLEEP bounds the time between two checks of the
+// schedules, so they follow wall clock adjustments.
+const SCHEDULER_MAX_SLEEP = time.Minute
+
+// experimentRunner starts and stops the experiments of a Scheduler.
+type experimentRunner interface {
+	// StartFor starts an experiment planned to run for d, or until
+	// it is stopped if d is zero.
+	StartFor(config *leto.TrackingConfiguration, d time.Duration) error
+	Stop() error
+	// RunningExperiment returns the name of the running
+	// experiment, if any.
+	RunningExperiment() (string, bool)
+}
+
+// Scheduler starts and stops experiments at planned times. Schedules
+// are persisted in a YAML file, so they survive daemon restarts.
+type Scheduler struct {
+	mx        sync.Mutex
+	path      string
+	schedules []leto.Schedule
+	runner    experimentRunner
+	profiles  *ProfileStore
+	events    *EventBroker
+	logger    *log.Logger
+	wake      chan struct{}
+	quit      chan struct{}
+	done      chan struct{}
+}
+
+// NewScheduler returns a Scheduler persisting its schedules in path,
+// loading the existing ones. Schedules which could not be executed
+// are published to events.
+func NewScheduler(path string, runner experimentRunner, profiles *ProfileStore, events *EventBroker) (*Scheduler, error) {
+	s := &Scheduler{
+		path:     path,
+		runner:   runner,
+		profiles: profiles,
+		events:   events,
+		logger:   newLogger("[schedule] "),
+		wake:     make(chan struct{}, 1),
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) == true {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, &s.schedules); err != nil {
+		return nil, fmt.Errorf("could not parse schedules in '%s': %s", path, err)
+	}
+	return s, nil
+}
+
+func (s *Scheduler) save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(s.schedules)
+	if err != nil {
+		return err
+	}
+	tmpPath := s.path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("Could not write schedules: %s", err)
+	}
+	return os.Rename(tmpPath, s.path)
+}
+
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// List returns the schedules by start time.
+func (s *Scheduler) List() []leto.Schedule {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	res := append([]leto.Schedule(nil), s.schedules...)
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Start.Before(res[j].Start) })
+	return res
+}
+
+func (s *Scheduler) resolveConfiguration(sc leto.Schedule) (*leto.TrackingConfiguration, error) {
+	config, err := leto.ParseConfiguration([]byte(sc.YamlConfiguration))
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %s", err)
+	}
+	if len(sc.Profile) > 0 {
+		overrides := config
+		config, err = s.profiles.Load(sc.Profile)
+		if err != nil {
+			return nil, err
+		}
+		if err := config.Merge(overrides); err != nil {
+			return nil, err
+		}
+	}
+	if err := config.Validate().ToError(); err != nil {
+		return nil, fmt.Errorf("invalid tracking configuration: %s", err)
+	}
+	if len(config.ExperimentName) == 0 {
+		return nil, fmt.Errorf("scheduled experiments must have a name")
+	}
+	return config, nil
+}
+
+// end returns the end of sc, or the largest time if it has none.
+func end(sc leto.Schedule) time.Time {
+	if sc.End.IsZero() == true {
+		return time.Unix(1<<62, 0)
+	}
+	return sc.End
+}
+
+// plannedDuration returns how long the experiment of sc runs if
+// started at now, or zero if it has no end.
+func plannedDuration(sc leto.Schedule, now time.Time) time.Duration {
+	if sc.End.IsZero() == true {
+		return 0
+	}
+	return sc.End.Sub(now)
+}
+
+func (s *Scheduler) checkOverlap(sc leto.Schedule) error {
+	if name, ok := s.runner.RunningExperiment(); ok == true {
+		// the running experiment may have been started manually,
+		// and may only be stopped by a schedule.
+		var stop *leto.Schedule
+		for i, other := range s.schedules {
+			if other.Started == true && other.ExperimentName == name {
+				stop = &s.schedules[i]
+			}
+		}
+		if stop == nil {
+			return fmt.Errorf("overlaps running experiment '%s' which has no planned stop", name)
+		}
+		if sc.Start.Before(end(*stop)) {
+			return fmt.Errorf("overlaps running experiment '%s' until %s", name, stop.End.Format(time.RFC3339))
+		}
+	}
+	for _, other := range s.schedules {
+		if other.StopOnly == true {
+			continue
+		}
+		if sc.Start.Before(end(other)) && other.Start.Before(end(sc)) {
+			return fmt.Errorf("overlaps schedule %s of experiment '%s'", other.ID, other.ExperimentName)
+		}
+	}
+	return nil
+}
+
+// Add validates and adds a schedule. An experiment without start
+// time is started immediately.
+func (s *Scheduler) Add(sc leto.Schedule) (leto.Schedule, error) {
+	now := time.Now()
+	sc.ID = uuid.New().String()[:8]
+	sc.Started = false
+	startNow := sc.Start.IsZero()
+	if startNow == true {
+		sc.Start = now
+	} else if sc.Start.Before(now) {
+		return sc, fmt.Errorf("start time %s is in the past", sc.Start.Format(time.RFC3339))
+	}
+	if sc.Duration < 0 {
+		return sc, fmt.Errorf("invalid negative duration %s", sc.Duration)
+	}
+	if sc.Duration > 0 {
+		if sc.End.IsZero() == false {
+			return sc, fmt.Errorf("cannot set both an end time and a duration")
+		}
+		sc.End = sc.Start.Add(sc.Duration)
+	}
+	sc.Duration = 0
+	if sc.End.IsZero() == false && sc.End.After(sc.Start) == false {
+		return sc, fmt.Errorf("end time %s is not after start time %s", sc.End.Format(time.RFC3339), sc.Start.Format(time.RFC3339))
+	}
+
+	if sc.StopOnly == true {
+		if startNow == false {
+			return sc, fmt.Errorf("a scheduled stop cannot have a start time")
+		}
+		if sc.End.IsZero() == true {
+			return sc, fmt.Errorf("a scheduled stop needs an end time or a duration")
+		}
+		name, ok := s.runner.RunningExperiment()
+		if ok == false {
+			return sc, fmt.Errorf("no experiment is running")
+		}
+		sc.ExperimentName = name
+		sc.Started = true
+		sc.Profile = ""
+		sc.YamlConfiguration = ""
+	} else {
+		config, err := s.resolveConfiguration(sc)
+		if err != nil {
+			return sc, err
+		}
+		yamlConfig, err := config.Yaml()
+		if err != nil {
+			return sc, err
+		}
+		sc.YamlConfiguration = string(yamlConfig)
+		sc.ExperimentName = config.ExperimentName
+
+		s.mx.Lock()
+		err = s.checkOverlap(sc)
+		s.mx.Unlock()
+		if err != nil {
+			return sc, err
+		}
+
+		if startNow == true {
+			if err := s.runner.StartFor(config, plannedDuration(sc, now)); err != nil {
+				return sc, err
+			}
+			sc.Started = true
+			if sc.End.IsZero() == true {
+				// nothing left to do
+				return sc, nil
+			}
+		}
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.schedules = append(s.schedules, sc)
+	s.notify()
+	s.logger.Printf("added schedule %s for experiment '%s'", sc.ID, sc.ExperimentName)
+	return sc, s.save()
+}
+
+// Cancel removes the schedule id. A started experiment is not stopped.
+func (s *Scheduler) Cancel(id string) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	for i, sc := range s.schedules {
+		if sc.ID != id {
+			continue
+		}
+		s.schedules = append(s.schedules[:i], s.schedules[i+1:]...)
+		s.notify()
+		s.logger.Printf("cancelled schedule %s for experiment '%s'", sc.ID, sc.ExperimentName)
+		return s.save()
+	}
+	return fmt.Errorf("unknown schedule '%s'", id)
+}
+
+// update calls f on the schedule id, if it was not cancelled
+// meanwhile, and removes it if f returns false.
+func (s *Scheduler) update(id string, f func(sc *leto.Schedule) bool) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	for i := range s.schedules {
+		if s.schedules[i].ID != id {
+			continue
+		}
+		if f(&s.schedules[i]) == false {
+			s.schedules = append(s.schedules[:i], s.schedules[i+1:]...)
+		}
+		if err := s.save(); err != nil {
+			s.logger.Printf("Could not save schedules: %s", err)
+		}
+		return
+	}
+}
+
+func (s *Scheduler) isDue(sc leto.Schedule, now time.Time) bool {
+	if sc.Started == false {
+		return now.Before(sc.Start) == false
+	}
+	return sc.End.IsZero() == false && now.Before(sc.End) == false
+}
+
+// fail logs and publishes that the schedule sc could not be
+// executed.
+func (s *Scheduler) fail(sc leto.Schedule, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	s.logger.Printf("schedule %s: %s", sc.ID, message)
+	s.events.Publish(leto.Event{
+		Type:       leto.EVENT_SCHEDULE_FAILED,
+		Experiment: sc.ExperimentName,
+		Message:    fmt.Sprintf("schedule %s: %s", sc.ID, message),
+	})
+}
+
+func (s *Scheduler) execute(sc leto.Schedule, now time.Time) {
+	if sc.Started == true {
+		name, ok := s.runner.RunningExperiment()
+		if ok == false || name != sc.ExperimentName {
+			s.logger.Printf("schedule %s: experiment '%s' is not running anymore", sc.ID, sc.ExperimentName)
+		} else {
+			s.logger.Printf("schedule %s: stopping experiment '%s'", sc.ID, sc.ExperimentName)
+			if err := s.runner.Stop(); err != nil {
+				s.fail(sc, "could not stop experiment '%s': %s", sc.ExperimentName, err)
+			}
+		}
+		s.update(sc.ID, func(*leto.Schedule) bool { return false })
+		return
+	}
+
+	if sc.End.IsZero() == false && now.Before(sc.End) == false {
+		s.fail(sc, "missed experiment '%s' from %s to %s", sc.ExperimentName, sc.Start.Format(time.RFC3339), sc.End.Format(time.RFC3339))
+		s.update(sc.ID, func(*leto.Schedule) bool { return false })
+		return
+	}
+
+	s.logger.Printf("schedule %s: starting experiment '%s'", sc.ID, sc.ExperimentName)
+	config, err := leto.ParseConfiguration([]byte(sc.YamlConfiguration))
+	if err == nil {
+		err = s.runner.StartFor(config, plannedDuration(sc, now))
+	}
+	if err != nil {
+		s.fail(sc, "could not start experiment '%s': %s", sc.ExperimentName, err)
+		s.update(sc.ID, func(*leto.Schedule) bool { return false })
+		return
+	}
+	s.update(sc.ID, func(sc *leto.Schedule) bool {
+		sc.Started = true
+		return sc.End.IsZero() == false
+	})
+}
+
+// process executes all schedules due at now, and returns when the
+// next one is due.
+func (s *Scheduler) process(now time.Time) time.Time {
+	s.mx.Lock()
+	due := []leto.Schedule{}
+	for _, sc := range s.schedules {
+		if s.isDue(sc, now) == true {
+			due = append(due, sc)
+		}
+	}
+	s.mx.Unlock()
+
+	// stops first, as an experiment may start when another ends.
+	sort.SliceStable(due, func(i, j int) bool { return due[i].Started && due[j].Started == false })
+	for _, sc := range due {
+		s.execute(sc, now)
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	next := now.Add(SCHEDULER_MAX_SLEEP)
+	for _, sc := range s.schedules {
+		t := sc.Start
+		if sc.Started == true {
+			t = sc.End
+		}
+		if t.IsZero() == false && t.Before(next) {
+			next = t
+		}
+	}
+	return next
+}
+
+// Run executes the schedules until Close is called.
+func (s *Scheduler) Run() {
+	defer close(s.done)
+	for {
+		next := s.process(time.Now())
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-s.quit:
+			timer.Stop()
+			return
+		case <-s.wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// Close stops Run.
+func (s *Scheduler) Close() {
+	close(s.quit)
+	<-s.done
+}
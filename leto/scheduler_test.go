@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/formicidae-tracker/leto"
+	. "gopkg.in/check.v1"
+)
+
+type fakeRunner struct {
+	running   string
+	started   []string
+	stopped   []string
+	durations []time.Duration
+}
+
+func (r *fakeRunner) StartFor(config *leto.TrackingConfiguration, d time.Duration) error {
+	if len(r.running) > 0 {
+		return fmt.Errorf("already running '%s'", r.running)
+	}
+	r.running = config.ExperimentName
+	r.started = append(r.started, config.ExperimentName)
+	r.durations = append(r.durations, d)
+	return nil
+}
+
+func (r *fakeRunner) Stop() error {
+	r.stopped = append(r.stopped, r.running)
+	r.running = ""
+	return nil
+}
+
+func (r *fakeRunner) RunningExperiment() (string, bool) {
+	return r.running, len(r.running) > 0
+}
+
+type SchedulerSuite struct {
+	tmpDir string
+	runner *fakeRunner
+	events *EventBroker
+	s      *Scheduler
+}
+
+var _ = Suite(&SchedulerSuite{})
+
+func (s *SchedulerSuite) path() string {
+	return filepath.Join(s.tmpDir, "schedules.yml")
+}
+
+func (s *SchedulerSuite) SetUpTest(c *C) {
+	var err error
+	s.tmpDir, err = ioutil.TempDir("", "leto-scheduler-tests")
+	c.Assert(err, IsNil)
+	s.runner = &fakeRunner{}
+	s.events = NewEventBroker("foo", EVENT_HISTORY_SIZE)
+	s.s, err = NewScheduler(s.path(), s.runner, NewProfileStore(filepath.Join(s.tmpDir, "profiles")), s.events)
+	c.Assert(err, IsNil)
+}
+
+func (s *SchedulerSuite) TearDownTest(c *C) {
+	c.Check(os.RemoveAll(s.tmpDir), IsNil)
+}
+
+func (s *SchedulerSuite) TestStartAndStopAtPlannedTimes(c *C) {
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	sc, err := s.s.Add(leto.Schedule{
+		Start:             start,
+		Duration:          72 * time.Hour,
+		YamlConfiguration: "experiment: lights-off\n",
+	})
+	c.Assert(err, IsNil)
+	c.Check(sc.ID, Not(Equals), "")
+	c.Check(sc.End.Equal(start.Add(72*time.Hour)), Equals, true)
+	c.Check(sc.ExperimentName, Equals, "lights-off")
+
+	next := s.s.process(start.Add(-time.Second))
+	c.Check(next.Equal(start), Equals, true)
+	c.Check(s.runner.started, HasLen, 0)
+
+	next = s.s.process(start)
+	c.Check(s.runner.started, DeepEquals, []string{"lights-off"})
+	c.Check(s.runner.durations, DeepEquals, []time.Duration{72 * time.Hour})
+	c.Check(next.Equal(start.Add(SCHEDULER_MAX_SLEEP)), Equals, true)
+	c.Assert(s.s.List(), HasLen, 1)
+	c.Check(s.s.List()[0].Started, Equals, true)
+
+	// schedules survive restarts
+	reloaded, err := NewScheduler(s.path(), s.runner, nil, nil)
+	c.Assert(err, IsNil)
+	c.Assert(reloaded.List(), HasLen, 1)
+	c.Check(reloaded.List()[0].End.Equal(sc.End), Equals, true)
+	c.Check(reloaded.List()[0].Started, Equals, true)
+
+	reloaded.process(sc.End)
+	c.Check(s.runner.stopped, DeepEquals, []string{"lights-off"})
+	c.Check(reloaded.List(), HasLen, 0)
+}
+
+func (s *SchedulerSuite) TestValidation(c *C) {
+	now := time.Now()
+	testdata := []struct {
+		Schedule leto.Schedule
+		Error    string
+	}{
+		{
+			leto.Schedule{Start: now.Add(-time.Hour), YamlConfiguration: "experiment: foo\n"},
+			"start time .* is in the past",
+		},
+		{
+			leto.Schedule{Start: now.Add(time.Hour), End: now.Add(time.Minute), YamlConfiguration: "experiment: foo\n"},
+			"end time .* is not after start time .*",
+		},
+		{
+			leto.Schedule{Start: now.Add(time.Hour), End: now.Add(2 * time.Hour), Duration: time.Hour, YamlConfiguration: "experiment: foo\n"},
+			"cannot set both an end time and a duration",
+		},
+		{
+			leto.Schedule{Start: now.Add(time.Hour)},
+			"scheduled experiments must have a name",
+		},
+		{
+			leto.Schedule{Start: now.Add(time.Hour), Profile: "none"},
+			"unknown profile 'none'",
+		},
+		{
+			leto.Schedule{StopOnly: true, Duration: time.Hour},
+			"no experiment is running",
+		},
+		{
+			leto.Schedule{StopOnly: true},
+			"a scheduled stop needs an end time or a duration",
+		},
+	}
+	for _, d := range testdata {
+		_, err := s.s.Add(d.Schedule)
+		c.Check(err, ErrorMatches, d.Error)
+	}
+	c.Check(s.s.List(), HasLen, 0)
+}
+
+func (s *SchedulerSuite) TestRejectsOverlaps(c *C) {
+	start := time.Now().Add(time.Hour)
+	_, err := s.s.Add(leto.Schedule{Start: start, Duration: time.Hour, YamlConfiguration: "experiment: foo\n"})
+	c.Assert(err, IsNil)
+	_, err = s.s.Add(leto.Schedule{Start: start.Add(30 * time.Minute), YamlConfiguration: "experiment: bar\n"})
+	c.Check(err, ErrorMatches, "overlaps schedule .* of experiment 'foo'")
+	_, err = s.s.Add(leto.Schedule{Start: start.Add(time.Hour), YamlConfiguration: "experiment: bar\n"})
+	c.Check(err, IsNil)
+}
+
+func (s *SchedulerSuite) TestStopRunningExperiment(c *C) {
+	sc, err := s.s.Add(leto.Schedule{Duration: time.Hour, YamlConfiguration: "experiment: foo\n"})
+	c.Assert(err, IsNil)
+	c.Check(s.runner.started, DeepEquals, []string{"foo"})
+	c.Check(sc.Started, Equals, true)
+
+	// cancelling the stop lets the experiment run
+	c.Check(s.s.Cancel(sc.ID), IsNil)
+	c.Check(s.s.Cancel(sc.ID), ErrorMatches, "unknown schedule .*")
+
+	sc, err = s.s.Add(leto.Schedule{StopOnly: true, Duration: time.Hour})
+	c.Assert(err, IsNil)
+	c.Check(sc.ExperimentName, Equals, "foo")
+
+	// an experiment started manually is not stopped by the schedule
+	s.runner.running = "bar"
+	s.s.process(sc.End)
+	c.Check(s.runner.stopped, HasLen, 0)
+	c.Check(s.s.List(), HasLen, 0)
+}
+
+func (s *SchedulerSuite) TestMissedSchedulesAreDropped(c *C) {
+	start := time.Now().Add(time.Hour)
+	sc, err := s.s.Add(leto.Schedule{Start: start, Duration: time.Hour, YamlConfiguration: "experiment: foo\n"})
+	c.Assert(err, IsNil)
+	// the daemon was down for the whole experiment
+	s.s.process(sc.End.Add(time.Minute))
+	c.Check(s.runner.started, HasLen, 0)
+	c.Check(s.s.List(), HasLen, 0)
+}
+
+func (s *SchedulerSuite) TestRejectsOverlapsWithRunningExperiment(c *C) {
+	now := time.Now()
+	// started manually, without schedule
+	s.runner.running = "foo"
+	_, err := s.s.Add(leto.Schedule{Start: now.Add(time.Hour), YamlConfiguration: "experiment: bar\n"})
+	c.Check(err, ErrorMatches, "overlaps running experiment 'foo' which has no planned stop")
+
+	_, err = s.s.Add(leto.Schedule{StopOnly: true, Duration: 30 * time.Minute})
+	c.Assert(err, IsNil)
+	_, err = s.s.Add(leto.Schedule{Start: now.Add(10 * time.Minute), YamlConfiguration: "experiment: bar\n"})
+	c.Check(err, ErrorMatches, "overlaps running experiment 'foo' until .*")
+	_, err = s.s.Add(leto.Schedule{Start: now.Add(time.Hour), YamlConfiguration: "experiment: bar\n"})
+	c.Check(err, IsNil)
+}
+
+func (s *SchedulerSuite) TestFailuresArePublished(c *C) {
+	start := time.Now().Add(time.Hour)
+	sc, err := s.s.Add(leto.Schedule{Start: start, YamlConfiguration: "experiment: foo\n"})
+	c.Assert(err, IsNil)
+	s.runner.running = "bar"
+	s.s.process(start)
+	c.Check(s.runner.started, HasLen, 0)
+	c.Check(s.s.List(), HasLen, 0)
+
+	since := uint64(0)
+	events, _, unsubscribe := s.events.Subscribe(&since)
+	defer unsubscribe()
+	c.Assert(events, HasLen, 1)
+	c.Check(events[0].Type, Equals, leto.EVENT_SCHEDULE_FAILED)
+	c.Check(events[0].Experiment, Equals, "foo")
+	c.Check(events[0].Message, Equals, "schedule "+sc.ID+": could not start experiment 'foo': already running 'bar'")
+}
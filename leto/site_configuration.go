@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/formicidae-tracker/leto"
+)
+
+// SITE_CONFIGURATION_CHECK_PERIOD is the period at which the site
+// configuration is checked again to advertise its errors.
+const SITE_CONFIGURATION_CHECK_PERIOD = 30 * time.Second
+
+// siteConfigurationCheck caches the validation of the site
+// configuration, which is only parsed again when its path, size or
+// modification time change. Its zero value is ready to use.
+type siteConfigurationCheck struct {
+	mx      sync.Mutex
+	checked bool
+	path    string
+	size    int64
+	modTime time.Time
+	err     error
+}
+
+// Error returns the error of the site configuration at path, or nil if
+// it is valid or missing.
+func (c *siteConfigurationCheck) Error(path string) error {
+	var size int64
+	var modTime time.Time
+	if info, err := os.Stat(path); err == nil {
+		size, modTime = info.Size(), info.ModTime()
+	}
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	if c.checked == true && c.path == path && c.size == size && c.modTime.Equal(modTime) == true {
+		return c.err
+	}
+	_, _, c.err = leto.LoadDefaultConfigFile(path)
+	c.checked, c.path, c.size, c.modTime = true, path, size, modTime
+	return c.err
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type SiteConfigurationSuite struct {
+	tmpDir string
+}
+
+var _ = Suite(&SiteConfigurationSuite{})
+
+func (s *SiteConfigurationSuite) SetUpTest(c *C) {
+	var err error
+	s.tmpDir, err = ioutil.TempDir("", "leto-site-config-tests")
+	c.Assert(err, IsNil)
+}
+
+func (s *SiteConfigurationSuite) TearDownTest(c *C) {
+	c.Check(os.RemoveAll(s.tmpDir), IsNil)
+}
+
+func (s *SiteConfigurationSuite) TestIsOnlyParsedWhenChanged(c *C) {
+	path := filepath.Join(s.tmpDir, "leto.yml")
+	check := siteConfigurationCheck{}
+	c.Check(check.Error(path), IsNil)
+
+	c.Assert(ioutil.WriteFile(path, []byte("foo: bar\n"), 0644), IsNil)
+	c.Check(check.Error(path), ErrorMatches, "(?s)invalid site configuration .*field foo not found.*")
+
+	// the cached result is returned while the file is unchanged
+	modTime := time.Now().Add(-time.Hour)
+	c.Assert(os.Chtimes(path, modTime, modTime), IsNil)
+	err := check.Error(path)
+	c.Check(err, NotNil)
+	c.Check(check.Error(path), Equals, err)
+
+	c.Assert(ioutil.WriteFile(path, []byte("threads: 2\n"), 0644), IsNil)
+	c.Check(check.Error(path), IsNil)
+}
+
+func (s *SiteConfigurationSuite) TestIsAdvertised(c *C) {
+	opts := DefaultOptions()
+	opts.SiteConfigPath = filepath.Join(s.tmpDir, "leto.yml")
+	m := &ArtemisManager{options: opts}
+	c.Check(m.AdvertisedNode().Misconfigured, Equals, false)
+
+	c.Assert(ioutil.WriteFile(opts.SiteConfigPath, []byte("foo: bar\n"), 0644), IsNil)
+	c.Check(m.AdvertisedNode().Misconfigured, Equals, true)
+}
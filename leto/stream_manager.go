@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
@@ -21,9 +22,9 @@ type FFMpegCommand struct {
 	stdout io.ReadCloser
 }
 
-func NewFFMpegCommand(args []string, streamType string, logFileName string) (*FFMpegCommand, error) {
+func NewFFMpegCommand(ffmpegPath string, args []string, streamType string, logFileName string) (*FFMpegCommand, error) {
 	cmd := &FFMpegCommand{
-		ecmd: exec.Command("ffmpeg", args...),
+		ecmd: exec.Command(ffmpegPath, args...),
 	}
 	var err error
 	// Close on exec will be set by go runtime, ensuring this file
@@ -77,6 +78,8 @@ type StreamManager struct {
 
 	period time.Duration
 
+	ffmpegPath string
+
 	baseMovieName     string
 	baseFrameMatching string
 	encodeLogBase     string
@@ -84,6 +87,7 @@ type StreamManager struct {
 	saveLogBase       string
 
 	encodeCmd, streamCmd, saveCmd *FFMpegCommand
+	started                       bool
 
 	frameCorrespondance *os.File
 
@@ -97,11 +101,19 @@ type StreamManager struct {
 	quality     string
 	tune        string
 
+	// reconfigured requests a new film segment with the current
+	// settings.
+	reconfigured chan struct{}
+	// saveStopped disables the saving of film segments.
+	saveStopped bool
+
 	logger *log.Logger
+	events *EventBroker
 }
 
-func NewStreamManager(basedir string, fps float64, config leto.StreamConfiguration) (*StreamManager, error) {
+func NewStreamManager(ffmpegPath string, basedir string, fps float64, config leto.StreamConfiguration, events *EventBroker) (*StreamManager, error) {
 	res := &StreamManager{
+		ffmpegPath:        ffmpegPath,
 		baseMovieName:     filepath.Join(basedir, "stream.mp4"),
 		baseFrameMatching: filepath.Join(basedir, "stream.frame-matching.txt"),
 		encodeLogBase:     filepath.Join(basedir, "encoding.log"),
@@ -115,7 +127,9 @@ func NewStreamManager(basedir string, fps float64, config leto.StreamConfigurati
 		quality:           *config.Quality,
 		tune:              *config.Tune,
 		period:            2 * time.Hour,
-		logger:            log.New(os.Stderr, "[stream] ", 0),
+		reconfigured:      make(chan struct{}, 1),
+		logger:            newLogger("[stream] "),
+		events:            events,
 	}
 	if err := res.Check(); err != nil {
 		return nil, err
@@ -123,37 +137,59 @@ func NewStreamManager(basedir string, fps float64, config leto.StreamConfigurati
 	return res, nil
 }
 
-var presets = map[string]bool{
-	"ultrafast": true,
-	"superfast": true,
-	"veryfast":  true,
-	"faster":    true,
-	"fast":      true,
-	"medium":    true,
-	"slow":      true,
-	"slower":    true,
-	"veryslow":  true,
-}
-
-var tunes = map[string]bool{
-	"film":        true,
-	"animation":   true,
-	"grain":       true,
-	"stillimage":  true,
-	"fastdecode":  true,
-	"zerolatency": true,
-}
-
 func (m *StreamManager) Check() error {
-	if ok := presets[m.quality]; ok == false {
+	if ok := leto.X264Presets[m.quality]; ok == false {
 		return fmt.Errorf("unknown quality '%s'", m.quality)
 	}
-	if ok := tunes[m.tune]; ok == false {
+	if ok := leto.X264Tunes[m.tune]; ok == false {
 		return fmt.Errorf("unknown tune '%s'", m.tune)
 	}
 	return nil
 }
 
+// Reconfigure changes the encoding and streaming settings. The
+// current film segment is ended, and the next one uses the new
+// settings.
+func (s *StreamManager) Reconfigure(config leto.StreamConfiguration) error {
+	if ok := leto.X264Presets[*config.Quality]; ok == false {
+		return fmt.Errorf("unknown quality '%s'", *config.Quality)
+	}
+	if ok := leto.X264Tunes[*config.Tune]; ok == false {
+		return fmt.Errorf("unknown tune '%s'", *config.Tune)
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.bitrate = *config.BitRateKB
+	s.maxBitrate = int(float64(*config.BitRateKB) * *config.BitRateMaxRatio)
+	s.destAddress = *config.Host
+	s.quality = *config.Quality
+	s.tune = *config.Tune
+	s.logger.Printf("New settings: %dk (max %dk), quality '%s', tune '%s', streaming to '%s'", s.bitrate, s.maxBitrate, s.quality, s.tune, s.destAddress)
+
+	select {
+	case s.reconfigured <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// StopSaving ends the current film segment, and stops saving the
+// next ones. The video is still streamed.
+func (s *StreamManager) StopSaving() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.saveStopped == true {
+		return
+	}
+	s.logger.Printf("Stopping saving of film segments")
+	s.saveStopped = true
+	select {
+	case s.reconfigured <- struct{}{}:
+	default:
+	}
+}
+
 func (s *StreamManager) waitUnsafe() {
 
 	if s.encodeCmd != nil {
@@ -258,26 +294,48 @@ func (s *StreamManager) startTasks() error {
 		return err
 	}
 
-	s.encodeCmd, err = NewFFMpegCommand(s.encodeCommandArgs(), "encode", encodeLogName)
+	s.encodeCmd, err = NewFFMpegCommand(s.ffmpegPath, s.encodeCommandArgs(), "encode", encodeLogName)
 	if err != nil {
 		return err
 	}
 
-	s.saveCmd, err = NewFFMpegCommand(s.saveCommandArgs(mName), "save", saveLogName)
-	if err != nil {
-		return err
-	}
 	streamArgs := s.streamCommandArgs()
 
-	copyRoutine := func() error {
-		_, err := io.Copy(s.saveCmd.Stdin(), s.encodeCmd.Stdout())
-		s.encodeCmd.Stdout().Close()
-		s.saveCmd.Stdin().Close()
-		return err
+	if s.saveStopped == false {
+		s.saveCmd, err = NewFFMpegCommand(s.ffmpegPath, s.saveCommandArgs(mName), "save", saveLogName)
+		if err != nil {
+			return err
+		}
 	}
 
-	if len(streamArgs) > 0 {
-		s.streamCmd, err = NewFFMpegCommand(streamArgs, "stream", streamLogName)
+	var copyRoutine func() error
+	switch {
+	case s.saveCmd == nil && len(streamArgs) == 0:
+		copyRoutine = func() error {
+			_, err := io.Copy(ioutil.Discard, s.encodeCmd.Stdout())
+			s.encodeCmd.Stdout().Close()
+			return err
+		}
+	case s.saveCmd == nil:
+		s.streamCmd, err = NewFFMpegCommand(s.ffmpegPath, streamArgs, "stream", streamLogName)
+		if err != nil {
+			return err
+		}
+		copyRoutine = func() error {
+			_, err := io.Copy(s.streamCmd.Stdin(), s.encodeCmd.Stdout())
+			s.encodeCmd.Stdout().Close()
+			s.streamCmd.Stdin().Close()
+			return err
+		}
+	case len(streamArgs) == 0:
+		copyRoutine = func() error {
+			_, err := io.Copy(s.saveCmd.Stdin(), s.encodeCmd.Stdout())
+			s.encodeCmd.Stdout().Close()
+			s.saveCmd.Stdin().Close()
+			return err
+		}
+	default:
+		s.streamCmd, err = NewFFMpegCommand(s.ffmpegPath, streamArgs, "stream", streamLogName)
 		if err != nil {
 			return err
 		}
@@ -300,15 +358,29 @@ func (s *StreamManager) startTasks() error {
 		s.wg.Done()
 	}()
 
-	s.logger.Printf("Starting streaming to %s and %s", mName, s.destAddress)
+	if s.saveCmd != nil {
+		s.logger.Printf("Starting streaming to %s and %s", mName, s.destAddress)
+	} else {
+		s.logger.Printf("Starting streaming to %s, without saving", s.destAddress)
+	}
+	if s.started == true {
+		metrics.FFMpegRestarted()
+	}
+	s.started = true
 	err = s.encodeCmd.Start()
 	if err != nil {
 		return err
 	}
+	if s.saveCmd != nil {
+		s.events.Publish(leto.Event{
+			Type: leto.EVENT_STREAM_SEGMENT_CREATED,
+			Path: filepath.Base(mName),
+		})
 
-	err = s.saveCmd.Start()
-	if err != nil {
-		return err
+		err = s.saveCmd.Start()
+		if err != nil {
+			return err
+		}
 	}
 
 	if s.streamCmd != nil {
@@ -393,8 +465,17 @@ func (s *StreamManager) EncodeAndStreamMuxedStream(muxed io.Reader) {
 		currentFrame += 1
 
 		now := time.Now()
-		if now.After(nextFile) == true {
+		newSegment := now.After(nextFile)
+		if newSegment == true {
 			log.Printf("Creating new film segment after %s", s.period)
+		}
+		select {
+		case <-s.reconfigured:
+			s.logger.Printf("Creating new film segment with new settings")
+			newSegment = true
+		default:
+		}
+		if newSegment == true {
 			s.mx.Lock()
 			s.stopTasks()
 			s.waitUnsafe()
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/formicidae-tracker/leto"
+)
+
+// trackingUpdate tells which subsystems must be restarted to apply a
+// configuration change to a running experiment.
+type trackingUpdate struct {
+	Fields  []string
+	Stream  bool
+	Olympus bool
+	Artemis bool
+}
+
+// planTrackingUpdate returns the trackingUpdate applying the changed
+// configuration fields, or an error if one of them cannot change
+// while the experiment runs.
+func planTrackingUpdate(changed []string) (trackingUpdate, error) {
+	res := trackingUpdate{Fields: changed}
+	rejected := []string{}
+	for _, field := range changed {
+		switch {
+		case field == "highlights":
+			res.Artemis = true
+		case field == "stream.host":
+			res.Olympus = true
+			res.Stream = true
+		case strings.HasPrefix(field, "stream."):
+			res.Stream = true
+		default:
+			rejected = append(rejected, field)
+		}
+	}
+	if len(rejected) > 0 {
+		return res, fmt.Errorf("cannot change %s of a running experiment", strings.Join(rejected, ", "))
+	}
+	return res, nil
+}
+
+// Update applies overrides to the running experiment. Only the
+// highlights and the stream settings can change: the stream is
+// restarted in a new film segment, and artemis is restarted in the
+// same experiment directory. Update returns once the changes are
+// requested, not once artemis is restarted.
+func (m *ArtemisManager) Update(overrides *leto.TrackingConfiguration) error {
+	registerOlympus, err := m.applyUpdate(overrides)
+	if err != nil {
+		return err
+	}
+	// olympus may be slow to answer, it is not called with mx held.
+	if registerOlympus != nil {
+		registerOlympus()
+	}
+	return nil
+}
+
+// applyUpdate applies overrides to the running experiment, but for
+// the olympus registration. It returns the function moving the
+// registration to the new stream host, or nil if it does not change.
+func (m *ArtemisManager) applyUpdate(overrides *leto.TrackingConfiguration) (func(), error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	if err := m.requireState("update the experiment", leto.EXPERIMENT_RUNNING); err != nil {
+		return nil, err
+	}
+	if m.nodeConfig.IsMaster() == false {
+		return nil, fmt.Errorf("cannot update a slave, update its master '%s'", m.nodeConfig.Master)
+	}
+
+	updated := &leto.TrackingConfiguration{}
+	if err := updated.Merge(m.experimentConfig); err != nil {
+		return nil, err
+	}
+	// reset fields go back to the site configuration, not to their
+	// running value.
+	defaults := m.experimentConfig
+	if overrides != nil && len(overrides.Reset) > 0 {
+		site, _, err := leto.LoadDefaultConfigFile(m.options.SiteConfigPath)
+		if err != nil {
+			return nil, err
+		}
+		defaults = site
+	}
+	if err := updated.MergeWithDefaults(overrides, defaults); err != nil {
+		return nil, fmt.Errorf("could not merge user configuration: %s", err)
+	}
+	changed, err := leto.ChangedConfigurationFields(m.experimentConfig, updated)
+	if err != nil {
+		return nil, err
+	}
+	if len(changed) == 0 {
+		return nil, nil
+	}
+	plan, err := planTrackingUpdate(changed)
+	if err != nil {
+		return nil, err
+	}
+	if plan.Artemis == true && len(m.nodeConfig.Slaves) > 0 {
+		return nil, fmt.Errorf("cannot restart artemis of a master with slaves")
+	}
+	if err := updated.Validate().ToError(); err != nil {
+		return nil, fmt.Errorf("invalid tracking configuration: %s", err)
+	}
+
+	if plan.Stream == true {
+		if err := m.streamManager.Reconfigure(updated.Stream); err != nil {
+			return nil, err
+		}
+	}
+	var registerOlympus func()
+	if plan.Olympus == true {
+		previous := m.experimentConfig
+		registerOlympus = func() {
+			m.unregisterOlympus(previous)
+			// a stop meanwhile unregistered the new host
+			if atomic.LoadInt32(&m.running) == 1 {
+				m.registerOlympus(updated)
+			}
+		}
+	}
+	m.experimentConfig = updated
+	if plan.Artemis == true {
+		m.reloadLocalTracker()
+	}
+
+	m.writePersistentFile()
+	m.revision += 1
+	if err := m.backUpConfigToExperimentDir(plan.Fields); err != nil {
+		m.logger.Printf("Could not save configuration revision %d: %s", m.revision, err)
+	}
+	m.logger.Printf("Configuration revision %d: changed %s", m.revision, strings.Join(plan.Fields, ", "))
+	m.events.Publish(leto.Event{
+		Type:    leto.EVENT_CONFIGURATION_UPDATED,
+		Path:    leto.CONFIGURATION_HISTORY_FILE,
+		Message: fmt.Sprintf("revision %d: changed %s", m.revision, strings.Join(plan.Fields, ", ")),
+	})
+	return registerOlympus, nil
+}
+
+// reloadLocalTracker stops artemis, for the supervisor to start it
+// again with the current configuration. m.mx must be held.
+func (m *ArtemisManager) reloadLocalTracker() {
+	if m.artemisCmd == nil || m.artemisCmd.Process == nil {
+		// a crashed artemis is restarted with the current
+		// configuration anyway.
+		return
+	}
+	m.reloadTracker = true
+	if err := m.artemisCmd.Process.Signal(os.Interrupt); err != nil {
+		m.reloadTracker = false
+	}
+}
+
+// appendConfigurationHistory appends the current configuration to
+// the experiment leto.CONFIGURATION_HISTORY_FILE, as revision
+// m.revision which changed the fields changed.
+func (m *ArtemisManager) appendConfigurationHistory(changed []string) error {
+	data, err := m.experimentConfig.Yaml()
+	if err != nil {
+		return err
+	}
+	f, err := openAppend(filepath.Join(m.experimentDir, leto.CONFIGURATION_HISTORY_FILE))
+	if err != nil {
+		return err
+	}
+	description := "initial configuration"
+	if len(changed) > 0 {
+		description = "changed " + strings.Join(changed, ", ")
+	}
+	_, err = fmt.Fprintf(f, "---\n# revision %d at %s: %s\n%s", m.revision, time.Now().Format(time.RFC3339), description, data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
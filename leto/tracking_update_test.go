@@ -0,0 +1,142 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/formicidae-tracker/leto"
+	. "gopkg.in/check.v1"
+)
+
+type TrackingUpdateSuite struct {
+	tmpDir string
+	m      *ArtemisManager
+}
+
+var _ = Suite(&TrackingUpdateSuite{})
+
+func (s *TrackingUpdateSuite) SetUpTest(c *C) {
+	var err error
+	s.tmpDir, err = ioutil.TempDir("", "leto-tracking-update-tests")
+	c.Assert(err, IsNil)
+	opts := DefaultOptions()
+	opts.DataDir = s.tmpDir
+	config := leto.RecommendedTrackingConfiguration()
+	config.ExperimentName = "foo"
+	config.Loads = generateLoadBalancing(NodeConfiguration{})
+	events := NewEventBroker("foo", EVENT_HISTORY_SIZE)
+	s.m = &ArtemisManager{
+		options:          opts,
+		logger:           newLogger("[artemis] "),
+		stateChanged:     make(chan struct{}, 1),
+		events:           events,
+		state:            leto.EXPERIMENT_RUNNING,
+		experimentDir:    s.tmpDir,
+		experimentConfig: &config,
+	}
+	s.m.streamManager, err = NewStreamManager(opts.FFMpegPath, s.tmpDir, 8.0, config.Stream, events)
+	c.Assert(err, IsNil)
+	c.Assert(s.m.backUpConfigToExperimentDir(nil), IsNil)
+}
+
+func (s *TrackingUpdateSuite) TearDownTest(c *C) {
+	c.Check(os.RemoveAll(s.tmpDir), IsNil)
+}
+
+func (s *TrackingUpdateSuite) TestPlan(c *C) {
+	plan, err := planTrackingUpdate([]string{"stream.bitrate", "highlights"})
+	c.Assert(err, IsNil)
+	c.Check(plan, DeepEquals, trackingUpdate{
+		Fields:  []string{"stream.bitrate", "highlights"},
+		Stream:  true,
+		Artemis: true,
+	})
+
+	plan, err = planTrackingUpdate([]string{"stream.host"})
+	c.Assert(err, IsNil)
+	c.Check(plan.Olympus, Equals, true)
+	c.Check(plan.Stream, Equals, true)
+
+	_, err = planTrackingUpdate([]string{"experiment", "stream.quality", "camera.fps"})
+	c.Check(err, ErrorMatches, "cannot change experiment, camera.fps of a running experiment")
+}
+
+func (s *TrackingUpdateSuite) TestRejectsUpdates(c *C) {
+	bitrate := 4000
+	overrides := &leto.TrackingConfiguration{Stream: leto.StreamConfiguration{BitRateKB: &bitrate}}
+
+	s.m.state = leto.EXPERIMENT_STOPPING
+	c.Check(s.m.Update(overrides), ErrorMatches, "cannot update the experiment while the node is stopping")
+
+	s.m.state = leto.EXPERIMENT_RUNNING
+	s.m.nodeConfig.Master = "bar"
+	c.Check(s.m.Update(overrides), ErrorMatches, "cannot update a slave, update its master 'bar'")
+
+	s.m.nodeConfig = NodeConfiguration{Slaves: []string{"baz"}}
+	c.Check(s.m.Update(&leto.TrackingConfiguration{Highlights: &[]int{1}}), ErrorMatches, "cannot restart artemis of a master with slaves")
+
+	c.Check(s.m.Update(&leto.TrackingConfiguration{ExperimentName: "bar"}), ErrorMatches, "cannot change experiment of a running experiment")
+	c.Check(s.m.experimentConfig.ExperimentName, Equals, "foo")
+}
+
+func (s *TrackingUpdateSuite) TestAppliesAndRecordsRevisions(c *C) {
+	_, events, unsubscribe := s.m.Events().Subscribe(nil)
+	defer unsubscribe()
+
+	bitrate := 4000
+	c.Assert(s.m.Update(&leto.TrackingConfiguration{
+		Stream:     leto.StreamConfiguration{BitRateKB: &bitrate},
+		Highlights: &[]int{1, 2},
+	}), IsNil)
+	c.Check(*s.m.experimentConfig.Stream.BitRateKB, Equals, 4000)
+	c.Check(*s.m.experimentConfig.Highlights, DeepEquals, []int{1, 2})
+	c.Check(s.m.streamManager.bitrate, Equals, 4000)
+
+	e := <-events
+	c.Check(e.Type, Equals, leto.EVENT_CONFIGURATION_UPDATED)
+	c.Check(e.Message, Equals, "revision 1: changed stream.bitrate, highlights")
+
+	// an update without changes is not a revision
+	c.Assert(s.m.Update(&leto.TrackingConfiguration{Highlights: &[]int{1, 2}}), IsNil)
+	c.Check(s.m.revision, Equals, 1)
+
+	final, err := leto.ReadConfiguration(filepath.Join(s.tmpDir, "leto-final-config.yml"))
+	c.Assert(err, IsNil)
+	c.Check(*final.Stream.BitRateKB, Equals, 4000)
+
+	persisted, err := leto.ReadConfiguration(s.m.persitentFilePath())
+	c.Assert(err, IsNil)
+	c.Check(*persisted.Highlights, DeepEquals, []int{1, 2})
+
+	history, err := ioutil.ReadFile(filepath.Join(s.tmpDir, leto.CONFIGURATION_HISTORY_FILE))
+	c.Assert(err, IsNil)
+	revisions := strings.Split(string(history), "---\n")[1:]
+	c.Assert(revisions, HasLen, 2)
+	c.Check(revisions[0], Matches, "(?s)# revision 0 at .*: initial configuration\n.*bitrate: 2000\n.*")
+	c.Check(revisions[1], Matches, "(?s)# revision 1 at .*: changed stream.bitrate, highlights\n.*bitrate: 4000\n.*")
+}
+
+func (s *TrackingUpdateSuite) TestOlympusIsRegisteredOutsideOfTheLock(c *C) {
+	host := "olympus.local"
+	register, err := s.m.applyUpdate(&leto.TrackingConfiguration{Stream: leto.StreamConfiguration{Host: &host}})
+	c.Assert(err, IsNil)
+	c.Check(register, NotNil)
+	c.Check(*s.m.experimentConfig.Stream.Host, Equals, host)
+
+	bitrate := 4000
+	register, err = s.m.applyUpdate(&leto.TrackingConfiguration{Stream: leto.StreamConfiguration{BitRateKB: &bitrate}})
+	c.Assert(err, IsNil)
+	c.Check(register, IsNil)
+}
+
+func (s *TrackingUpdateSuite) TestResetsToTheSiteConfiguration(c *C) {
+	s.m.options.SiteConfigPath = filepath.Join(s.tmpDir, "leto.yml")
+	c.Assert(ioutil.WriteFile(s.m.options.SiteConfigPath, []byte("stream:\n  bitrate: 3000\n"), 0644), IsNil)
+
+	bitrate := 4000
+	c.Assert(s.m.Update(&leto.TrackingConfiguration{Stream: leto.StreamConfiguration{BitRateKB: &bitrate}}), IsNil)
+	c.Assert(s.m.Update(&leto.TrackingConfiguration{Reset: []string{"stream.bitrate"}}), IsNil)
+	c.Check(*s.m.experimentConfig.Stream.BitRateKB, Equals, 3000)
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -30,3 +31,8 @@ func FilenameWithoutOverwrite(fpath string) (string, int, error) {
 		iter += 1
 	}
 }
+
+func isLoopback(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	return ok == true && tcpAddr.IP.IsLoopback()
+}
@@ -0,0 +1,118 @@
+package leto
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/adrg/xdg"
+	"gopkg.in/yaml.v2"
+)
+
+// DEFAULT_NODE_INVENTORY_PATH is the system wide static inventory of
+// leto nodes, used when zeroconf discovery is not available.
+const DEFAULT_NODE_INVENTORY_PATH = "/etc/default/leto-nodes.yml"
+
+// NodeInventory is a static list of nodes, for networks where mDNS
+// is filtered. Only the node name and address are mandatory.
+type NodeInventory struct {
+	Nodes []Node `yaml:"nodes"`
+}
+
+// NodeInventoryPaths returns the inventory files to read, in order
+// of precedence: the user's one overrides the system wide one.
+func NodeInventoryPaths() []string {
+	return []string{
+		DEFAULT_NODE_INVENTORY_PATH,
+		filepath.Join(xdg.ConfigHome, "FORmicidae Tracker", "leto-nodes.yml"),
+	}
+}
+
+func (n *Node) setInventoryDefaults(source string) {
+	if n.Port == 0 {
+		n.Port = LETO_PORT
+	}
+	if n.ArtemisInPort == 0 {
+		n.ArtemisInPort = ARTEMIS_IN_PORT
+	}
+	if n.ArtemisOutPort == 0 {
+		n.ArtemisOutPort = ARTEMIS_OUT_PORT
+	}
+	n.Sources = []string{source}
+}
+
+// LoadNodeInventory reads a static node inventory. A missing file is
+// an empty inventory.
+func LoadNodeInventory(path string) (map[string]Node, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) == true {
+			return nil, nil
+		}
+		return nil, err
+	}
+	inventory := NodeInventory{}
+	if err := yaml.UnmarshalStrict(data, &inventory); err != nil {
+		return nil, fmt.Errorf("invalid node inventory '%s': %s", path, err)
+	}
+	res := make(map[string]Node, len(inventory.Nodes))
+	for i, n := range inventory.Nodes {
+		if len(n.Name) == 0 || len(n.Address) == 0 {
+			return nil, fmt.Errorf("invalid node inventory '%s': node %d needs a name and an address", path, i)
+		}
+		if _, ok := res[n.Name]; ok == true {
+			return nil, fmt.Errorf("invalid node inventory '%s': duplicated node '%s'", path, n.Name)
+		}
+		n.setInventoryDefaults(NODE_SOURCE_INVENTORY)
+		res[n.Name] = n
+	}
+	return res, nil
+}
+
+// ParseNodeAddress parses a node given as host or host:port on the
+// command line. The node is named after the first label of host,
+// like zeroconf instances are named after their hostname.
+func ParseNodeAddress(address string) (Node, error) {
+	host, portStr, err := net.SplitHostPort(address)
+	port := 0
+	if err != nil {
+		host = address
+	} else {
+		port, err = strconv.Atoi(portStr)
+		if err != nil || port <= 0 || port > 65535 {
+			return Node{}, fmt.Errorf("invalid port in node address '%s'", address)
+		}
+	}
+	if len(host) == 0 {
+		return Node{}, fmt.Errorf("invalid node address '%s'", address)
+	}
+	name := host
+	if net.ParseIP(host) == nil {
+		name = strings.SplitN(host, ".", 2)[0]
+	}
+	res := Node{Name: name, Address: host, Port: port}
+	res.setInventoryDefaults(NODE_SOURCE_COMMAND_LINE)
+	return res, nil
+}
+
+// MergeNodes merges a newly discovered node into a known one. The
+// discovered information is more recent and takes precedence, but
+// the provenance of both is kept.
+func MergeNodes(known, discovered Node) Node {
+	res := discovered
+	res.Sources = append([]string(nil), known.Sources...)
+	for _, source := range discovered.Sources {
+		found := false
+		for _, s := range res.Sources {
+			found = found || s == source
+		}
+		if found == false {
+			res.Sources = append(res.Sources, source)
+		}
+	}
+	return res
+}
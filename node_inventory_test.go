@@ -0,0 +1,114 @@
+package leto
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+)
+
+type NodeInventorySuite struct {
+	tmpDir string
+}
+
+var _ = Suite(&NodeInventorySuite{})
+
+func (s *NodeInventorySuite) SetUpTest(c *C) {
+	var err error
+	s.tmpDir, err = ioutil.TempDir("", "leto-inventory-tests")
+	c.Assert(err, IsNil)
+}
+
+func (s *NodeInventorySuite) TearDownTest(c *C) {
+	c.Check(os.RemoveAll(s.tmpDir), IsNil)
+}
+
+func (s *NodeInventorySuite) TestLoadInventory(c *C) {
+	nodes, err := LoadNodeInventory(filepath.Join(s.tmpDir, "absent.yml"))
+	c.Check(err, IsNil)
+	c.Check(nodes, HasLen, 0)
+
+	testdata := []struct {
+		YAML     string
+		Expected string
+	}{
+		{"nodes:\n  - name: foo\n", `invalid node inventory '.*': node 0 needs a name and an address`},
+		{"nodes:\n  - name: foo\n    address: foo.lab\n  - name: foo\n    address: bar.lab\n", `invalid node inventory '.*': duplicated node 'foo'`},
+		{"nodes:\n  - name: foo\n    adress: foo.lab\n", `invalid node inventory '.*': yaml: unmarshal errors:\n.*`},
+	}
+
+	path := filepath.Join(s.tmpDir, "leto-nodes.yml")
+	for _, d := range testdata {
+		c.Assert(ioutil.WriteFile(path, []byte(d.YAML), 0644), IsNil)
+		_, err := LoadNodeInventory(path)
+		c.Check(err, ErrorMatches, d.Expected)
+	}
+
+	c.Assert(ioutil.WriteFile(path, []byte(`
+nodes:
+  - name: foo
+    address: 192.168.1.12
+  - name: bar
+    address: bar.lab
+    port: 5000
+    artemis-out-port: 5002
+`), 0644), IsNil)
+	nodes, err = LoadNodeInventory(path)
+	c.Assert(err, IsNil)
+	c.Check(nodes, DeepEquals, map[string]Node{
+		"foo": Node{
+			Name:           "foo",
+			Address:        "192.168.1.12",
+			Port:           LETO_PORT,
+			ArtemisInPort:  ARTEMIS_IN_PORT,
+			ArtemisOutPort: ARTEMIS_OUT_PORT,
+			Sources:        []string{NODE_SOURCE_INVENTORY},
+		},
+		"bar": Node{
+			Name:           "bar",
+			Address:        "bar.lab",
+			Port:           5000,
+			ArtemisInPort:  ARTEMIS_IN_PORT,
+			ArtemisOutPort: 5002,
+			Sources:        []string{NODE_SOURCE_INVENTORY},
+		},
+	})
+}
+
+func (s *NodeInventorySuite) TestParseNodeAddress(c *C) {
+	testdata := []struct {
+		Address string
+		Name    string
+		Host    string
+		Port    int
+	}{
+		{"foo", "foo", "foo", LETO_PORT},
+		{"foo.lab.example.com:5000", "foo", "foo.lab.example.com", 5000},
+		{"192.168.1.12:5000", "192.168.1.12", "192.168.1.12", 5000},
+	}
+	for _, d := range testdata {
+		n, err := ParseNodeAddress(d.Address)
+		if c.Check(err, IsNil) == false {
+			continue
+		}
+		c.Check(n.Name, Equals, d.Name)
+		c.Check(n.Address, Equals, d.Host)
+		c.Check(n.Port, Equals, d.Port)
+		c.Check(n.Sources, DeepEquals, []string{NODE_SOURCE_COMMAND_LINE})
+	}
+
+	_, err := ParseNodeAddress("foo:bar")
+	c.Check(err, ErrorMatches, `invalid port in node address 'foo:bar'`)
+	_, err = ParseNodeAddress(":4000")
+	c.Check(err, ErrorMatches, `invalid node address ':4000'`)
+}
+
+func (s *NodeInventorySuite) TestMergeKeepsProvenance(c *C) {
+	known := Node{Name: "foo", Address: "foo.lab", Sources: []string{NODE_SOURCE_INVENTORY}}
+	discovered := Node{Name: "foo", Address: "foo.local", Version: "v0.5.0", Sources: []string{NODE_SOURCE_ZEROCONF}}
+	merged := MergeNodes(known, discovered)
+	c.Check(merged.Address, Equals, "foo.local")
+	c.Check(merged.Version, Equals, "v0.5.0")
+	c.Check(merged.Sources, DeepEquals, []string{NODE_SOURCE_INVENTORY, NODE_SOURCE_ZEROCONF})
+}
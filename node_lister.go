@@ -4,9 +4,10 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
-	"net/rpc"
+	"log"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -18,35 +19,183 @@ import (
 type NodeLister struct {
 	CacheDate time.Time       `yaml:"date"`
 	Cache     map[string]Node `yaml:"nodes"`
+	// TTL is the duration cached nodes are used without browsing the
+	// network again.
+	TTL time.Duration `yaml:"-"`
+
+	cachePath      string
+	inventoryPaths []string
+	browse         func(window time.Duration) (map[string]Node, error)
 }
 
 type Node struct {
-	Name    string `yaml:"name"`
-	Address string `yaml:"address"`
-	Port    int    `yaml:"port"`
+	Name           string    `yaml:"name"`
+	Address        string    `yaml:"address"`
+	Port           int       `yaml:"port"`
+	ArtemisInPort  int       `yaml:"artemis-in-port"`
+	ArtemisOutPort int       `yaml:"artemis-out-port"`
+	Version        string    `yaml:"version,omitempty"`
+	Role           string    `yaml:"role,omitempty"`
+	Master         string    `yaml:"master,omitempty"`
+	Experiment     string    `yaml:"experiment,omitempty"`
+	Since          time.Time `yaml:"since,omitempty"`
+	// Misconfigured is true if the site configuration of the node is
+	// invalid, see Status.SiteConfigurationError.
+	Misconfigured bool     `yaml:"misconfigured,omitempty"`
+	Sources       []string `yaml:"sources,omitempty"`
 }
 
-func (n Node) RunMethod(name string, args, reply interface{}) error {
-	c, err := rpc.DialHTTP("tcp", fmt.Sprintf("%s:%d", n.Address, n.Port))
-	if err != nil {
-		return fmt.Errorf("Could not connect to '%s': %s", n.Name, err)
+// Sources a node can be known from.
+const (
+	NODE_SOURCE_ZEROCONF     = "zeroconf"
+	NODE_SOURCE_INVENTORY    = "inventory"
+	NODE_SOURCE_COMMAND_LINE = "command-line"
+)
+
+// Roles of a node in a cluster.
+const (
+	NODE_ROLE_STANDALONE = "standalone"
+	NODE_ROLE_MASTER     = "master"
+	NODE_ROLE_SLAVE      = "slave"
+)
+
+// Keys of the zeroconf TXT records advertised by leto instances.
+const (
+	TXT_ARTEMIS_IN_PORT  = "artemis-in-port"
+	TXT_ARTEMIS_OUT_PORT = "artemis-out-port"
+	TXT_VERSION          = "version"
+	TXT_ROLE             = "role"
+	TXT_MASTER           = "master"
+	TXT_EXPERIMENT       = "experiment"
+	TXT_SINCE            = "since"
+	TXT_MISCONFIGURED    = "misconfigured"
+)
+
+// TXTRecords returns the zeroconf TXT records advertising n. Empty
+// fields are not advertised.
+func (n Node) TXTRecords() []string {
+	res := []string{
+		fmt.Sprintf("%s=%d", TXT_ARTEMIS_IN_PORT, n.ArtemisInPort),
+		fmt.Sprintf("%s=%d", TXT_ARTEMIS_OUT_PORT, n.ArtemisOutPort),
+	}
+	for _, r := range []struct{ key, value string }{
+		{TXT_VERSION, n.Version},
+		{TXT_ROLE, n.Role},
+		{TXT_MASTER, n.Master},
+		{TXT_EXPERIMENT, n.Experiment},
+	} {
+		if len(r.value) > 0 {
+			res = append(res, r.key+"="+r.value)
+		}
+	}
+	if n.Since.IsZero() == false {
+		res = append(res, TXT_SINCE+"="+n.Since.UTC().Format(time.RFC3339))
+	}
+	if n.Misconfigured == true {
+		res = append(res, TXT_MISCONFIGURED+"=true")
+	}
+	return res
+}
+
+// Advertised returns true if n was discovered with its version, role
+// and experiment, which older leto instances do not advertise.
+func (n Node) Advertised() bool {
+	return len(n.Version) > 0
+}
+
+func parseTXTRecords(records []string) map[string]string {
+	res := make(map[string]string, len(records))
+	for _, r := range records {
+		kv := strings.SplitN(r, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		res[kv[0]] = kv[1]
+	}
+	return res
+}
+
+func parsePortRecord(records map[string]string, key string, defaultPort int) int {
+	port, err := strconv.Atoi(records[key])
+	if err != nil || port <= 0 || port > 65535 {
+		return defaultPort
+	}
+	return port
+}
+
+// NewNodeFromService builds a Node from a zeroconf service
+// entry. Ports that are not advertised, like by older leto
+// instances, are set to their default value.
+func NewNodeFromService(e *zeroconf.ServiceEntry) Node {
+	records := parseTXTRecords(e.Text)
+	res := Node{
+		Name:           strings.TrimPrefix(e.Instance, "leto."),
+		Address:        strings.TrimSuffix(e.HostName, "."),
+		Port:           e.Port,
+		ArtemisInPort:  parsePortRecord(records, TXT_ARTEMIS_IN_PORT, ARTEMIS_IN_PORT),
+		ArtemisOutPort: parsePortRecord(records, TXT_ARTEMIS_OUT_PORT, ARTEMIS_OUT_PORT),
+		Version:        records[TXT_VERSION],
+		Role:           records[TXT_ROLE],
+		Master:         records[TXT_MASTER],
+		Experiment:     records[TXT_EXPERIMENT],
+		Misconfigured:  records[TXT_MISCONFIGURED] == "true",
+		Sources:        []string{NODE_SOURCE_ZEROCONF},
+	}
+	if since, err := time.Parse(time.RFC3339, records[TXT_SINCE]); err == nil {
+		res.Since = since
 	}
-	defer c.Close()
-	return c.Call(name, args, reply)
+	return res
+}
+
+// Call calls method on n with DefaultRPCClient, see RPCClient.Call.
+func (n Node) Call(ctx context.Context, method string, args, reply interface{}) error {
+	return DefaultRPCClient.Call(ctx, n, method, args, reply)
+}
+
+// RunMethod calls method name on n without deadline.
+//
+// Deprecated: use Call, which can be given a deadline.
+func (n Node) RunMethod(name string, args, reply interface{}) error {
+	return n.Call(context.Background(), name, args, reply)
 }
 
 func NewNodeLister() *NodeLister {
-	res := &NodeLister{}
+	res := newNodeLister(filepath.Join(xdg.CacheHome, "fort/leto/node.cache"))
 	res.load()
 	return res
 }
 
-func (n *NodeLister) cacheFilePath() string {
-	return filepath.Join(xdg.CacheHome, "fort/leto/node.cache")
+// DefaultNodeCacheTTL is the TTL of new NodeLister. leto and leto-cli
+// set it from their --node-cache-ttl option.
+var DefaultNodeCacheTTL = nodeCacheTTL()
+
+func newNodeLister(cachePath string) *NodeLister {
+	return &NodeLister{
+		TTL:            DefaultNodeCacheTTL,
+		cachePath:      cachePath,
+		inventoryPaths: NodeInventoryPaths(),
+		browse:         browse,
+	}
+}
+
+// nodeCacheTTL returns the node cache TTL, which can be set with the
+// LETO_NODE_CACHE_TTL environment variable, like "30s".
+func nodeCacheTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("LETO_NODE_CACHE_TTL"))
+	if err != nil || ttl < 0 {
+		return NODE_CACHE_TTL
+	}
+	return ttl
+}
+
+// CacheFilePath returns the path of the node cache, shared by all
+// leto-cli and leto processes of a user.
+func (n *NodeLister) CacheFilePath() string {
+	return n.cachePath
 }
 
 func (n *NodeLister) load() {
-	cachedData, err := ioutil.ReadFile(n.cacheFilePath())
+	cachedData, err := ioutil.ReadFile(n.cachePath)
 	if err != nil {
 		return
 	}
@@ -54,31 +203,84 @@ func (n *NodeLister) load() {
 	if err != nil {
 		n.CacheDate = time.Now().Add(-10 * time.Hour)
 	}
+	// caches written by older versions do not hold artemis ports
+	for name, node := range n.Cache {
+		if node.ArtemisInPort == 0 {
+			node.ArtemisInPort = ARTEMIS_IN_PORT
+		}
+		if node.ArtemisOutPort == 0 {
+			node.ArtemisOutPort = ARTEMIS_OUT_PORT
+		}
+		n.Cache[name] = node
+	}
 }
 
-func (n *NodeLister) save() {
-	if err := os.MkdirAll(filepath.Dir(n.cacheFilePath()), 0755); err != nil {
-		return
+// save writes the cache to a temporary file renamed over the previous
+// one, so concurrent processes never read a partially written cache.
+func (n *NodeLister) save() error {
+	dir := filepath.Dir(n.cachePath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
 	}
 	yamlData, err := yaml.Marshal(n)
 	if err != nil {
-		return
+		return err
 	}
 
-	ioutil.WriteFile(n.cacheFilePath(), yamlData, 0644)
+	tmp, err := ioutil.TempFile(dir, filepath.Base(n.cachePath)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(yamlData)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), n.cachePath)
 }
 
-func (n *NodeLister) ListNodes() (map[string]Node, error) {
-	if time.Now().Before(n.CacheDate.Add(NODE_CACHE_TTL)) == true {
-		return n.Cache, nil
+// Valid returns true if the cache is not older than TTL.
+func (n *NodeLister) Valid() bool {
+	return time.Now().Before(n.CacheDate.Add(n.TTL))
+}
+
+// Evict removes a node from the cache, so it will be looked for
+// again on the network by the next ListNodes. The cache is read again
+// first, to not override the nodes saved by other processes.
+func (n *NodeLister) Evict(name string) error {
+	n.Cache = nil
+	n.load()
+	if _, ok := n.Cache[name]; ok == false {
+		return nil
+	}
+	delete(n.Cache, name)
+	return n.save()
+}
+
+// Flush removes all cached nodes.
+func (n *NodeLister) Flush() error {
+	n.Cache = nil
+	n.CacheDate = time.Time{}
+	if err := os.Remove(n.cachePath); err != nil && os.IsNotExist(err) == false {
+		return err
 	}
+	return nil
+}
 
+// browse collects the leto instances answering within window.
+func browse(window time.Duration) (map[string]Node, error) {
 	resolver, err := zeroconf.NewResolver(nil)
 	if err != nil {
 		return nil, fmt.Errorf("Could not create resolver: %s", err)
 	}
 	entries := make(chan *zeroconf.ServiceEntry, 100)
-	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), window)
 	defer cancel()
 	err = resolver.Browse(ctx, "_leto._tcp", "local.", entries)
 	if err != nil {
@@ -90,10 +292,20 @@ func (n *NodeLister) ListNodes() (map[string]Node, error) {
 	res := make(map[string]Node)
 
 	for e := range entries {
-		name := strings.TrimPrefix(e.Instance, "leto.")
-		address := strings.TrimSuffix(e.HostName, ".")
-		port := e.Port
-		res[name] = Node{Name: name, Address: address, Port: port}
+		node := NewNodeFromService(e)
+		res[node.Name] = node
+	}
+	return res, nil
+}
+
+func (n *NodeLister) browseNodes() (map[string]Node, error) {
+	if n.Valid() == true {
+		return n.Cache, nil
+	}
+
+	res, err := n.browse(NODE_BROWSE_WINDOW)
+	if err != nil {
+		return nil, err
 	}
 	n.Cache = res
 	n.CacheDate = time.Now()
@@ -101,5 +313,128 @@ func (n *NodeLister) ListNodes() (map[string]Node, error) {
 	n.save()
 
 	return res, nil
+}
+
+// ListNodes returns the nodes found in the static inventories and
+// discovered over zeroconf. If zeroconf is not available, only the
+// inventory nodes are returned. Invalid inventories are logged and
+// skipped.
+func (n *NodeLister) ListNodes() (map[string]Node, error) {
+	res := make(map[string]Node)
+	for _, path := range n.inventoryPaths {
+		inventory, err := LoadNodeInventory(path)
+		if err != nil {
+			log.Printf("Ignoring node inventory: %s", err)
+			continue
+		}
+		for name, node := range inventory {
+			res[name] = node
+		}
+	}
+
+	discovered, err := n.browseNodes()
+	if err != nil {
+		if len(res) == 0 {
+			return nil, err
+		}
+		return res, nil
+	}
 
+	for name, node := range discovered {
+		res[name] = MergeNodes(res[name], node)
+	}
+
+	return res, nil
+}
+
+// MissingNodesError is returned by ListExpectedNodes when some
+// expected nodes could not be found before the deadline.
+type MissingNodesError struct {
+	Missing []string
+}
+
+func (e *MissingNodesError) Error() string {
+	return fmt.Sprintf("could not find node(s) %s", strings.Join(e.Missing, ", "))
+}
+
+func missingNodes(nodes map[string]Node, expected []string) []string {
+	var res []string
+	for _, name := range expected {
+		if _, ok := nodes[name]; ok == false {
+			res = append(res, name)
+		}
+	}
+	return res
+}
+
+// ListExpectedNodes is like ListNodes, but keeps browsing until all
+// expected nodes are found or timeout expires. Browses are retried
+// after NODE_BROWSE_RETRY_DELAY, and each retry waits and browses
+// twice longer than the previous one, up to
+// NODE_BROWSE_MAX_WINDOW. If some nodes are still missing, the nodes
+// that were found are returned alongside a *MissingNodesError. The
+// cache date is only refreshed by browses lasting at least
+// NODE_BROWSE_WINDOW, like the ones of ListNodes.
+func (n *NodeLister) ListExpectedNodes(expected []string, timeout time.Duration) (map[string]Node, error) {
+	deadline := time.Now().Add(timeout)
+	res, err := n.ListNodes()
+	if err != nil {
+		res = make(map[string]Node)
+	}
+
+	if n.Cache == nil {
+		n.Cache = make(map[string]Node)
+	}
+
+	window := NODE_BROWSE_WINDOW
+	delay := time.Duration(0)
+	browsed := false
+	for missing := missingNodes(res, expected); len(missing) > 0; missing = missingNodes(res, expected) {
+		if delay > 0 {
+			if delay >= deadline.Sub(time.Now()) {
+				break
+			}
+			time.Sleep(delay)
+		}
+		remaining := deadline.Sub(time.Now())
+		if remaining <= 0 {
+			break
+		}
+		if window > remaining {
+			window = remaining
+		}
+		discovered, err := n.browse(window)
+		if err != nil {
+			// zeroconf is not available, there is no need to wait
+			return res, &MissingNodesError{Missing: missing}
+		}
+		browsed = true
+		if window >= NODE_BROWSE_WINDOW {
+			n.CacheDate = time.Now()
+		}
+		for name, node := range discovered {
+			res[name] = MergeNodes(res[name], node)
+			n.Cache[name] = node
+		}
+
+		if delay == 0 {
+			delay = NODE_BROWSE_RETRY_DELAY
+		} else {
+			delay *= 2
+		}
+		window *= 2
+		if delay > NODE_BROWSE_MAX_WINDOW {
+			delay = NODE_BROWSE_MAX_WINDOW
+		}
+		if window > NODE_BROWSE_MAX_WINDOW {
+			window = NODE_BROWSE_MAX_WINDOW
+		}
+	}
+	if browsed == true {
+		n.save()
+	}
+	if missing := missingNodes(res, expected); len(missing) > 0 {
+		return res, &MissingNodesError{Missing: missing}
+	}
+	return res, nil
 }
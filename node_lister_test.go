@@ -0,0 +1,180 @@
+package leto
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/grandcat/zeroconf"
+	. "gopkg.in/check.v1"
+)
+
+type NodeListerSuite struct{}
+
+var _ = Suite(&NodeListerSuite{})
+
+func (s *NodeListerSuite) TestNodeFromService(c *C) {
+	advertised := Node{
+		Name:           "foo",
+		Address:        "foo.local",
+		Port:           5000,
+		ArtemisInPort:  5001,
+		ArtemisOutPort: 5002,
+		Version:        "v0.5.0",
+		Role:           NODE_ROLE_SLAVE,
+		Master:         "bar",
+		Experiment:     "colony-42",
+		Since:          time.Date(2020, 03, 12, 15, 42, 03, 0, time.UTC),
+		Misconfigured:  true,
+		Sources:        []string{NODE_SOURCE_ZEROCONF},
+	}
+	e := zeroconf.NewServiceEntry("leto.foo", "_leto._tcp", "local.")
+	e.HostName = "foo.local."
+	e.Port = 5000
+	e.Text = advertised.TXTRecords()
+	c.Check(NewNodeFromService(e), DeepEquals, advertised)
+	c.Check(advertised.Advertised(), Equals, true)
+
+	// older instances do not advertise their ports
+	e.Text = []string{"artemis-in-port=foo"}
+	c.Check(NewNodeFromService(e), DeepEquals, Node{
+		Name:           "foo",
+		Address:        "foo.local",
+		Port:           5000,
+		ArtemisInPort:  ARTEMIS_IN_PORT,
+		ArtemisOutPort: ARTEMIS_OUT_PORT,
+		Sources:        []string{NODE_SOURCE_ZEROCONF},
+	})
+}
+
+func (s *NodeListerSuite) TestReportsMissingNodes(c *C) {
+	nodes := map[string]Node{"foo": Node{Name: "foo"}}
+	c.Check(missingNodes(nodes, []string{"foo"}), IsNil)
+	missing := missingNodes(nodes, []string{"bar", "foo", "baz"})
+	c.Check(missing, DeepEquals, []string{"bar", "baz"})
+	var err error = &MissingNodesError{Missing: missing}
+	c.Check(err, ErrorMatches, `could not find node\(s\) bar, baz`)
+}
+
+func (s *NodeListerSuite) TestCacheCanBeEvictedAndFlushed(c *C) {
+	tmpDir, err := ioutil.TempDir("", "leto-node-cache-tests")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(tmpDir)
+	path := filepath.Join(tmpDir, "fort/leto/node.cache")
+
+	n := newNodeLister(path)
+	c.Check(n.Valid(), Equals, false)
+	n.Cache = map[string]Node{
+		"foo": Node{Name: "foo", Address: "foo.local", Port: LETO_PORT},
+		"bar": Node{Name: "bar", Address: "bar.local", Port: LETO_PORT},
+	}
+	n.CacheDate = time.Now()
+	c.Assert(n.save(), IsNil)
+
+	loaded := newNodeLister(path)
+	loaded.load()
+	c.Check(loaded.Valid(), Equals, true)
+	c.Check(loaded.Cache, HasLen, 2)
+
+	// nodes saved meanwhile by other processes are kept
+	n.Cache["baz"] = Node{Name: "baz", Address: "baz.local", Port: LETO_PORT}
+	c.Assert(n.save(), IsNil)
+
+	c.Check(loaded.Evict("foo"), IsNil)
+	loaded = newNodeLister(path)
+	loaded.load()
+	c.Check(loaded.Cache, HasLen, 2)
+	_, ok := loaded.Cache["bar"]
+	c.Check(ok, Equals, true)
+	_, ok = loaded.Cache["baz"]
+	c.Check(ok, Equals, true)
+
+	// no temporary file is left behind
+	files, err := ioutil.ReadDir(filepath.Dir(path))
+	c.Assert(err, IsNil)
+	c.Check(files, HasLen, 1)
+
+	c.Check(loaded.Flush(), IsNil)
+	c.Check(loaded.Valid(), Equals, false)
+	_, err = os.Stat(path)
+	c.Check(os.IsNotExist(err), Equals, true)
+	c.Check(loaded.Flush(), IsNil)
+
+	n.TTL = 0
+	c.Check(n.Valid(), Equals, false)
+}
+
+func (s *NodeListerSuite) TestSkipsInvalidInventories(c *C) {
+	tmpDir, err := ioutil.TempDir("", "leto-node-lister-tests")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(tmpDir)
+	invalid := filepath.Join(tmpDir, "invalid.yml")
+	valid := filepath.Join(tmpDir, "valid.yml")
+	c.Assert(ioutil.WriteFile(invalid, []byte("nodes: [{name: foo}]\n"), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(valid, []byte("nodes: [{name: bar, address: bar.lan}]\n"), 0644), IsNil)
+
+	n := newNodeLister(filepath.Join(tmpDir, "node.cache"))
+	n.inventoryPaths = []string{invalid, valid}
+	n.Cache = map[string]Node{"baz": Node{Name: "baz", Address: "baz.local", Port: LETO_PORT}}
+	n.CacheDate = time.Now()
+
+	nodes, err := n.ListNodes()
+	c.Assert(err, IsNil)
+	c.Check(nodes, HasLen, 2)
+	c.Check(nodes["bar"].Address, Equals, "bar.lan")
+	c.Check(nodes["baz"].Address, Equals, "baz.local")
+}
+
+func (s *NodeListerSuite) TestExpectedNodesAreBrowsedWithBackoff(c *C) {
+	tmpDir, err := ioutil.TempDir("", "leto-node-lister-tests")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(tmpDir)
+
+	n := newNodeLister(filepath.Join(tmpDir, "node.cache"))
+	n.inventoryPaths = nil
+	// a valid cache spares the browse of ListNodes
+	n.TTL = time.Hour
+	cacheDate := time.Now().Add(-time.Minute)
+	n.CacheDate = cacheDate
+	var starts []time.Time
+	var windows []time.Duration
+	n.browse = func(window time.Duration) (map[string]Node, error) {
+		starts = append(starts, time.Now())
+		windows = append(windows, window)
+		res := map[string]Node{}
+		if len(starts) == 3 {
+			res["foo"] = Node{Name: "foo", Address: "foo.local", Port: LETO_PORT}
+		}
+		return res, nil
+	}
+
+	nodes, err := n.ListExpectedNodes([]string{"foo"}, 5*time.Second)
+	c.Assert(err, IsNil)
+	c.Check(nodes["foo"].Address, Equals, "foo.local")
+	c.Check(windows, DeepEquals, []time.Duration{NODE_BROWSE_WINDOW, 2 * NODE_BROWSE_WINDOW, 4 * NODE_BROWSE_WINDOW})
+	c.Assert(starts, HasLen, 3)
+	c.Check(starts[2].Sub(starts[1]) >= 2*NODE_BROWSE_RETRY_DELAY, Equals, true)
+	c.Check(n.CacheDate.After(cacheDate), Equals, true)
+
+	// a browse shortened by the deadline does not refresh the cache
+	n.CacheDate = cacheDate
+	windows = nil
+	n.browse = func(window time.Duration) (map[string]Node, error) {
+		windows = append(windows, window)
+		return nil, nil
+	}
+	nodes, err = n.ListExpectedNodes([]string{"bar"}, NODE_BROWSE_WINDOW/2)
+	c.Check(err, ErrorMatches, `could not find node\(s\) bar`)
+	c.Check(windows, HasLen, 1)
+	c.Check(windows[0] < NODE_BROWSE_WINDOW, Equals, true)
+	c.Check(n.CacheDate, Equals, cacheDate)
+}
+
+func (s *NodeListerSuite) TestCacheTTLCanBeSetFromEnvironment(c *C) {
+	defer os.Unsetenv("LETO_NODE_CACHE_TTL")
+	os.Setenv("LETO_NODE_CACHE_TTL", "1m")
+	c.Check(nodeCacheTTL(), Equals, time.Minute)
+	os.Setenv("LETO_NODE_CACHE_TTL", "foo")
+	c.Check(nodeCacheTTL(), Equals, NODE_CACHE_TTL)
+}
@@ -6,31 +6,123 @@ import (
 )
 
 type Response struct {
-	Error string
+	Error string `json:"error"`
 }
 
 type NoArgs struct {
 }
 
+// Status is the state of a node. Its JSON encoding is part of the
+// REST API and must stay stable.
 type Status struct {
-	Master     string
-	Slaves     []string
-	Experiment *ExperimentStatus
+	Master                 string            `json:"master"`
+	Slaves                 []string          `json:"slaves"`
+	Experiment             *ExperimentStatus `json:"experiment"`
+	SiteConfigurationError string            `json:"site_configuration_error"`
+	State                  ExperimentState   `json:"state"`
+	// Failure is the reason of the EXPERIMENT_FAILED state.
+	Failure string `json:"failure"`
 }
 
+// ExperimentStatus describes the running experiment of a node. Its
+// JSON encoding is part of the REST API and must stay stable.
 type ExperimentStatus struct {
-	Since             time.Time
-	ExperimentDir     string
+	Since             time.Time `json:"since"`
+	ExperimentDir     string    `json:"experiment_dir"`
+	YamlConfiguration string    `json:"yaml_configuration"`
+	// DroppedFrames is the number of frames missing from the
+	// tracking files.
+	DroppedFrames uint64 `json:"dropped_frames"`
+	// FrameGaps are the most recent ranges of missing frames. All
+	// ranges are listed in the experiment FRAME_GAPS_FILE.
+	FrameGaps []FrameGap `json:"frame_gaps"`
+	// Crashes are the unexpected exits of artemis since the
+	// experiment started.
+	Crashes []ArtemisCrash `json:"crashes"`
+	// Disk is the free space of the experiment filesystem, or nil
+	// if it could not be checked yet.
+	Disk *DiskStatus `json:"disk"`
+}
+
+// DiskLevel tells how close the filesystem of an experiment is to be
+// full.
+type DiskLevel string
+
+const (
+	DISK_OK       DiskLevel = "ok"
+	DISK_WARNING  DiskLevel = "warning"
+	DISK_CRITICAL DiskLevel = "critical"
+)
+
+// DiskStatus is the free space of the filesystem of a running
+// experiment. Its JSON encoding is part of the REST API and must stay
+// stable.
+type DiskStatus struct {
+	// Free is the available space in bytes.
+	Free  uint64    `json:"free"`
+	Level DiskLevel `json:"level"`
+	// VideoSavingStopped is true once video saving was stopped to
+	// keep the remaining space for tracking data.
+	VideoSavingStopped bool `json:"video_saving_stopped"`
+}
+
+// ArtemisCrash is an unexpected exit of artemis during an
+// experiment. Its JSON encoding is part of the REST API and must stay
+// stable.
+type ArtemisCrash struct {
+	Time time.Time `json:"time"`
+	// ExitCode is the exit code of artemis, or -1 if it was killed
+	// by a signal.
+	ExitCode int    `json:"exit_code"`
+	Error    string `json:"error"`
+	// Restarted is true if artemis was restarted after the crash.
+	Restarted bool `json:"restarted"`
+}
+
+// FRAME_GAPS_FILE lists, in an experiment directory, the ranges of
+// frames missing from the tracking files.
+const FRAME_GAPS_FILE = "tracking.gaps.csv"
+
+// CONFIGURATION_HISTORY_FILE lists, in an experiment directory, the
+// successive revisions of the experiment configuration, as a YAML
+// multi-document file.
+const CONFIGURATION_HISTORY_FILE = "leto-config-history.yml"
+
+// FrameGap is a range of consecutive frames missing from the tracking
+// files. Its JSON encoding is part of the REST API and must stay
+// stable.
+type FrameGap struct {
+	First  int64     `json:"first_frame"`
+	Last   int64     `json:"last_frame"`
+	Time   time.Time `json:"time"`
+	Reason string    `json:"reason"`
+}
+
+// Frames returns the number of frames in the gap.
+func (g FrameGap) Frames() int64 {
+	return g.Last - g.First + 1
+}
+
+type DefaultConfiguration struct {
 	YamlConfiguration string
+	Path              string
+	ModTime           time.Time
+	Error             string
 }
 
+// ExperimentLog is the log of a finished experiment. Its JSON
+// encoding is part of the REST API and must stay stable.
 type ExperimentLog struct {
-	Log               string
-	Stderr            string
-	ExperimentDir     string
-	Start, End        time.Time
-	YamlConfiguration string
-	HasError          bool
+	Log               string    `json:"log"`
+	Stderr            string    `json:"stderr"`
+	ExperimentDir     string    `json:"experiment_dir"`
+	Start             time.Time `json:"start"`
+	End               time.Time `json:"end"`
+	YamlConfiguration string    `json:"yaml_configuration"`
+	HasError          bool      `json:"has_error"`
+	// Crashes are the unexpected exits of artemis during the
+	// experiment, including the last one if it ended it.
+	Crashes []ArtemisCrash `json:"crashes"`
 }
 
 func (r Response) ToError() error {
@@ -48,8 +140,8 @@ type SlaveTrackingStart struct {
 }
 
 type Link struct {
-	Master string
-	Slave  string
+	Master string `json:"master"`
+	Slave  string `json:"slave"`
 }
 
 type Unlink struct {
@@ -57,6 +149,57 @@ type Unlink struct {
 	Slave  string
 }
 
+type Profile struct {
+	Name              string
+	YamlConfiguration string
+}
+
+type ProfileList struct {
+	Profiles []Profile
+}
+
+// TrackingStart is the body of a REST start request. If Profile is
+// set, YamlConfiguration holds optional overrides of the profile.
+type TrackingStart struct {
+	Profile           string `json:"profile"`
+	YamlConfiguration string `json:"yaml_configuration"`
+}
+
+type ProfileTrackingStart struct {
+	Profile       string
+	YamlOverrides string
+}
+
+// Schedule is an experiment planned on a node.
+type Schedule struct {
+	// ID is set by the node when the schedule is added.
+	ID string `yaml:"id"`
+	// Start is when the experiment starts. If zero, it starts when
+	// the schedule is added.
+	Start time.Time `yaml:"start,omitempty"`
+	// End is when the experiment stops. If zero, it runs until it is
+	// stopped, unless Duration is set.
+	End time.Time `yaml:"end,omitempty"`
+	// Duration sets End from Start when the schedule is added.
+	Duration time.Duration `yaml:"-"`
+	// StopOnly schedules the stop of the running experiment at End,
+	// instead of a new experiment.
+	StopOnly bool `yaml:"stop-only,omitempty"`
+	// Profile is the profile the configuration is based on. If set,
+	// YamlConfiguration holds optional overrides when the schedule
+	// is added.
+	Profile           string `yaml:"profile,omitempty"`
+	YamlConfiguration string `yaml:"configuration,omitempty"`
+	// ExperimentName is set by the node when the schedule is added.
+	ExperimentName string `yaml:"experiment"`
+	// Started is set by the node once the experiment was started.
+	Started bool `yaml:"started"`
+}
+
+type ScheduleList struct {
+	Schedules []Schedule
+}
+
 type RegisterTrackerArgs struct {
 	Hostname       string
 	StreamServer   string
@@ -0,0 +1,236 @@
+package leto
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/rpc"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// UnreachableError is returned when a node cannot be connected to.
+type UnreachableError struct {
+	Node string
+	Err  error
+}
+
+func (e *UnreachableError) Error() string {
+	return fmt.Sprintf("could not connect to '%s': %s", e.Node, e.Err)
+}
+
+// TimeoutError is returned when a call did not complete before the
+// context deadline, or was cancelled.
+type TimeoutError struct {
+	Node   string
+	Method string
+	Err    error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s on '%s': %s", e.Method, e.Node, e.Err)
+}
+
+// RemoteError is returned when a node reported an error, either as a
+// net/rpc error or in a Response.
+type RemoteError struct {
+	Node    string
+	Method  string
+	Message string
+}
+
+func (e *RemoteError) Error() string {
+	return fmt.Sprintf("%s on '%s' failed: %s", e.Method, e.Node, e.Message)
+}
+
+// RPCClient calls methods on leto nodes. It keeps one connection per
+// node, which is reused by subsequent and concurrent calls. It is
+// safe for concurrent use.
+type RPCClient struct {
+	// Token, if not empty, authenticates the client to nodes
+	// requiring a pre-shared token.
+	Token string
+	// TLSConfig, if not nil, is used to connect to nodes over
+	// TLS. Its ServerName is set to each node address.
+	TLSConfig *tls.Config
+
+	mx      sync.Mutex
+	clients map[string]*pooledClient
+}
+
+// pooledClient is a connection shared by concurrent calls. A dropped
+// connection is not given to new calls anymore, and is closed once
+// its last call is done.
+type pooledClient struct {
+	*rpc.Client
+	users   int
+	dropped bool
+}
+
+func NewRPCClient() *RPCClient {
+	return &RPCClient{
+		clients: make(map[string]*pooledClient),
+	}
+}
+
+// DefaultRPCClient is the RPCClient shared by a process.
+var DefaultRPCClient = NewRPCClient()
+
+const rpcConnected = "200 Connected to Go RPC"
+
+func (c *RPCClient) dial(ctx context.Context, n Node) (net.Conn, error) {
+	address := net.JoinHostPort(n.Address, strconv.Itoa(n.Port))
+	if c.TLSConfig == nil {
+		dialer := net.Dialer{}
+		return dialer.DialContext(ctx, "tcp", address)
+	}
+	config := c.TLSConfig.Clone()
+	config.ServerName = n.Address
+	dialer := tls.Dialer{Config: config}
+	return dialer.DialContext(ctx, "tcp", address)
+}
+
+// dialHTTP is like rpc.DialHTTP, but honors ctx for the connection
+// and the HTTP handshake, and authenticates with the token.
+func (c *RPCClient) dialHTTP(ctx context.Context, n Node) (*rpc.Client, error) {
+	conn, err := c.dial(ctx, n)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok == true {
+		conn.SetDeadline(deadline)
+	}
+	request := "CONNECT " + rpc.DefaultRPCPath + " HTTP/1.0\n"
+	if len(c.Token) > 0 {
+		request += "Authorization: Bearer " + c.Token + "\n"
+	}
+	io.WriteString(conn, request+"\n")
+	resp, err := http.ReadResponse(bufio.NewReader(conn), &http.Request{Method: "CONNECT"})
+	if err == nil && resp.StatusCode == http.StatusUnauthorized {
+		err = errors.New("unauthorized, invalid or missing token")
+	} else if err == nil && resp.Status != rpcConnected {
+		err = fmt.Errorf("unexpected HTTP response: %s", resp.Status)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return rpc.NewClient(conn), nil
+}
+
+// client returns the pooled connection to address, connecting to n
+// if needed, and whether it was reused. It must be released once the
+// call is done.
+func (c *RPCClient) client(ctx context.Context, n Node, address string) (*pooledClient, bool, error) {
+	c.mx.Lock()
+	client, ok := c.clients[address]
+	if ok == true {
+		client.users += 1
+	}
+	c.mx.Unlock()
+	if ok == true {
+		return client, true, nil
+	}
+
+	rpcClient, err := c.dialHTTP(ctx, n)
+	if err != nil {
+		return nil, false, err
+	}
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	if other, ok := c.clients[address]; ok == true {
+		// a concurrent call was faster to connect
+		rpcClient.Close()
+		other.users += 1
+		return other, true, nil
+	}
+	client = &pooledClient{Client: rpcClient, users: 1}
+	c.clients[address] = client
+	return client, false, nil
+}
+
+// release ends a call on client. If drop is true, the connection is
+// not reused, and it is closed once no other call uses it.
+func (c *RPCClient) release(address string, client *pooledClient, drop bool) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	client.users -= 1
+	if drop == true && client.dropped == false {
+		client.dropped = true
+		if c.clients[address] == client {
+			delete(c.clients, address)
+		}
+	}
+	if client.dropped == true && client.users == 0 {
+		client.Close()
+	}
+}
+
+// Call calls method on node n. It returns a *TimeoutError if ctx is
+// done before the call completes, an *UnreachableError if the node
+// cannot be connected to, and a *RemoteError if the node returned an
+// error, including through reply if it is a *Response.
+func (c *RPCClient) Call(ctx context.Context, n Node, method string, args, reply interface{}) error {
+	address := net.JoinHostPort(n.Address, strconv.Itoa(n.Port))
+	for {
+		client, reused, err := c.client(ctx, n, address)
+		if err != nil {
+			if ctx.Err() != nil {
+				return &TimeoutError{Node: n.Name, Method: method, Err: ctx.Err()}
+			}
+			// the node may have moved or be gone, do not use the
+			// cached address anymore.
+			NewNodeLister().Evict(n.Name)
+			return &UnreachableError{Node: n.Name, Err: err}
+		}
+
+		call := client.Go(method, args, reply, make(chan *rpc.Call, 1))
+		select {
+		case <-ctx.Done():
+			// net/rpc calls cannot be cancelled, the connection may
+			// be stuck and should not be reused. Concurrent calls
+			// on it are left to complete.
+			c.release(address, client, true)
+			return &TimeoutError{Node: n.Name, Method: method, Err: ctx.Err()}
+		case <-call.Done:
+		}
+
+		var serverError rpc.ServerError
+		broken := call.Error != nil && errors.As(call.Error, &serverError) == false
+		c.release(address, client, broken)
+		switch {
+		case call.Error == nil:
+			if r, ok := reply.(*Response); ok == true && len(r.Error) > 0 {
+				return &RemoteError{Node: n.Name, Method: method, Message: r.Error}
+			}
+			return nil
+		case errors.As(call.Error, &serverError):
+			return &RemoteError{Node: n.Name, Method: method, Message: call.Error.Error()}
+		case call.Error == rpc.ErrShutdown && reused == true:
+			// the pooled connection was closed, the call was not sent
+			continue
+		default:
+			return &UnreachableError{Node: n.Name, Err: call.Error}
+		}
+	}
+}
+
+// Close closes all pooled connections.
+func (c *RPCClient) Close() error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	for address, client := range c.clients {
+		client.dropped = true
+		client.Close()
+		delete(c.clients, address)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package leto
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/rpc"
+	"sync/atomic"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type RPCTestService struct{}
+
+func (s *RPCTestService) Echo(args *string, reply *string) error {
+	*reply = *args
+	return nil
+}
+
+func (s *RPCTestService) Refuse(args *NoArgs, resp *Response) error {
+	resp.Error = "refused"
+	return nil
+}
+
+func (s *RPCTestService) Fail(args *NoArgs, resp *Response) error {
+	return errors.New("failed")
+}
+
+func (s *RPCTestService) Hang(args *NoArgs, resp *Response) error {
+	time.Sleep(200 * time.Millisecond)
+	return nil
+}
+
+type countingListener struct {
+	net.Listener
+	accepted int32
+}
+
+func (l *countingListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err == nil {
+		atomic.AddInt32(&l.accepted, 1)
+	}
+	return c, err
+}
+
+type RPCClientSuite struct {
+	listener *countingListener
+	server   *http.Server
+	node     Node
+	client   *RPCClient
+}
+
+var _ = Suite(&RPCClientSuite{})
+
+func (s *RPCClientSuite) SetUpTest(c *C) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	s.listener = &countingListener{Listener: l}
+	router := rpc.NewServer()
+	c.Assert(router.RegisterName("Test", &RPCTestService{}), IsNil)
+	s.server = &http.Server{Handler: router}
+	go s.server.Serve(s.listener)
+	s.node = Node{
+		Name:    "test-node",
+		Address: "127.0.0.1",
+		Port:    l.Addr().(*net.TCPAddr).Port,
+	}
+	s.client = NewRPCClient()
+}
+
+func (s *RPCClientSuite) TearDownTest(c *C) {
+	c.Check(s.client.Close(), IsNil)
+	s.server.Close()
+}
+
+func (s *RPCClientSuite) TestReusesConnections(c *C) {
+	for _, msg := range []string{"foo", "bar", "baz"} {
+		reply := ""
+		c.Check(s.client.Call(context.Background(), s.node, "Test.Echo", &msg, &reply), IsNil)
+		c.Check(reply, Equals, msg)
+	}
+	c.Check(atomic.LoadInt32(&s.listener.accepted), Equals, int32(1))
+}
+
+func (s *RPCClientSuite) TestReportsRemoteErrors(c *C) {
+	err := s.client.Call(context.Background(), s.node, "Test.Refuse", &NoArgs{}, &Response{})
+	c.Check(err, ErrorMatches, `Test.Refuse on 'test-node' failed: refused`)
+	_, ok := err.(*RemoteError)
+	c.Check(ok, Equals, true)
+
+	err = s.client.Call(context.Background(), s.node, "Test.Fail", &NoArgs{}, &Response{})
+	c.Check(err, ErrorMatches, `Test.Fail on 'test-node' failed: failed`)
+	_, ok = err.(*RemoteError)
+	c.Check(ok, Equals, true)
+
+	// the connection is still usable
+	msg, reply := "foo", ""
+	c.Check(s.client.Call(context.Background(), s.node, "Test.Echo", &msg, &reply), IsNil)
+	c.Check(atomic.LoadInt32(&s.listener.accepted), Equals, int32(1))
+}
+
+func (s *RPCClientSuite) TestTimesOut(c *C) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := s.client.Call(ctx, s.node, "Test.Hang", &NoArgs{}, &Response{})
+	c.Check(err, ErrorMatches, `Test.Hang on 'test-node': context deadline exceeded`)
+	_, ok := err.(*TimeoutError)
+	c.Check(ok, Equals, true)
+
+	// a new connection is used after a timeout
+	msg, reply := "foo", ""
+	c.Check(s.client.Call(context.Background(), s.node, "Test.Echo", &msg, &reply), IsNil)
+	c.Check(atomic.LoadInt32(&s.listener.accepted), Equals, int32(2))
+}
+
+func (s *RPCClientSuite) TestTimeoutDoesNotBreakConcurrentCalls(c *C) {
+	errs := make(chan error)
+	go func() {
+		errs <- s.client.Call(context.Background(), s.node, "Test.Hang", &NoArgs{}, &Response{})
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := s.client.Call(ctx, s.node, "Test.Hang", &NoArgs{}, &Response{})
+	c.Check(err, ErrorMatches, `Test.Hang on 'test-node': context deadline exceeded`)
+
+	c.Check(<-errs, IsNil)
+	c.Check(atomic.LoadInt32(&s.listener.accepted), Equals, int32(1))
+}
+
+func (s *RPCClientSuite) TestReconnects(c *C) {
+	msg, reply := "foo", ""
+	c.Check(s.client.Call(context.Background(), s.node, "Test.Echo", &msg, &reply), IsNil)
+	s.client.mx.Lock()
+	for _, client := range s.client.clients {
+		client.Close()
+	}
+	s.client.mx.Unlock()
+	c.Check(s.client.Call(context.Background(), s.node, "Test.Echo", &msg, &reply), IsNil)
+	c.Check(atomic.LoadInt32(&s.listener.accepted), Equals, int32(2))
+}
+
+func (s *RPCClientSuite) TestReportsUnreachableNodes(c *C) {
+	s.server.Close()
+	err := s.client.Call(context.Background(), s.node, "Test.Echo", &NoArgs{}, &Response{})
+	c.Check(err, ErrorMatches, `could not connect to 'test-node': .*`)
+	_, ok := err.(*UnreachableError)
+	c.Check(ok, Equals, true)
+}
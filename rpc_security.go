@@ -0,0 +1,124 @@
+package leto
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// RPCCredentials are the optional credentials protecting the RPC
+// control plane. They are shared by the leto daemon, for its server
+// and its calls to slaves, and by leto-cli. With a token, each
+// connection must present the same pre-shared token. With TLS
+// certificates, connections are encrypted and both ends must
+// present a certificate signed by the given CA.
+type RPCCredentials struct {
+	TokenFile string `long:"token-file" env:"LETO_TOKEN_FILE" description:"file holding the pre-shared token authenticating RPC connections"`
+	TLSCert   string `long:"tls-cert" env:"LETO_TLS_CERT" description:"certificate used for mutual TLS RPC connections"`
+	TLSKey    string `long:"tls-key" env:"LETO_TLS_KEY" description:"private key of --tls-cert"`
+	TLSCA     string `long:"tls-ca" env:"LETO_TLS_CA" description:"CA certificate verifying the peer of mutual TLS RPC connections"`
+}
+
+// Token returns the pre-shared token, or an empty string if none is
+// configured.
+func (c RPCCredentials) Token() (string, error) {
+	if len(c.TokenFile) == 0 {
+		return "", nil
+	}
+	data, err := ioutil.ReadFile(c.TokenFile)
+	if err != nil {
+		return "", fmt.Errorf("could not read token: %s", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if len(token) == 0 {
+		return "", fmt.Errorf("token file '%s' is empty", c.TokenFile)
+	}
+	return token, nil
+}
+
+// UsesTLS returns true if a TLS certificate is configured.
+func (c RPCCredentials) UsesTLS() bool {
+	return len(c.TLSCert) > 0 || len(c.TLSKey) > 0 || len(c.TLSCA) > 0
+}
+
+func (c RPCCredentials) tlsConfig() (*tls.Config, *x509.CertPool, error) {
+	if len(c.TLSCert) == 0 || len(c.TLSKey) == 0 || len(c.TLSCA) == 0 {
+		return nil, nil, fmt.Errorf("mutual TLS needs a certificate, a key and a CA")
+	}
+	cert, err := tls.LoadX509KeyPair(c.TLSCert, c.TLSKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not load TLS certificate: %s", err)
+	}
+	caData, err := ioutil.ReadFile(c.TLSCA)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read TLS CA: %s", err)
+	}
+	pool := x509.NewCertPool()
+	if pool.AppendCertsFromPEM(caData) == false {
+		return nil, nil, fmt.Errorf("no certificate found in TLS CA '%s'", c.TLSCA)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, pool, nil
+}
+
+// ServerTLSConfig returns the TLS configuration of an RPC server,
+// requiring clients certificates, or nil if TLS is not configured.
+func (c RPCCredentials) ServerTLSConfig() (*tls.Config, error) {
+	if c.UsesTLS() == false {
+		return nil, nil
+	}
+	config, pool, err := c.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	config.ClientCAs = pool
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+	return config, nil
+}
+
+// ClientTLSConfig returns the TLS configuration of an RPC client, or
+// nil if TLS is not configured.
+func (c RPCCredentials) ClientTLSConfig() (*tls.Config, error) {
+	if c.UsesTLS() == false {
+		return nil, nil
+	}
+	config, pool, err := c.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	config.RootCAs = pool
+	return config, nil
+}
+
+// ConfigureClient sets the credentials of client.
+func (c RPCCredentials) ConfigureClient(client *RPCClient) error {
+	var err error
+	if client.Token, err = c.Token(); err != nil {
+		return err
+	}
+	client.TLSConfig, err = c.ClientTLSConfig()
+	return err
+}
+
+// RequireToken wraps an RPC handler to refuse connections that do
+// not present token. An empty token accepts all connections.
+func RequireToken(handler http.Handler, token string) http.Handler {
+	if len(token) == 0 {
+		return handler
+	}
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		provided := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(provided, expected) != 1 {
+			http.Error(w, "invalid or missing token", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
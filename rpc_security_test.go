@@ -0,0 +1,175 @@
+package leto
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/rpc"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type RPCSecuritySuite struct {
+	tmpDir string
+}
+
+var _ = Suite(&RPCSecuritySuite{})
+
+func (s *RPCSecuritySuite) SetUpTest(c *C) {
+	var err error
+	s.tmpDir, err = ioutil.TempDir("", "leto-rpc-security-tests")
+	c.Assert(err, IsNil)
+}
+
+func (s *RPCSecuritySuite) TearDownTest(c *C) {
+	c.Check(os.RemoveAll(s.tmpDir), IsNil)
+}
+
+func (s *RPCSecuritySuite) writePEM(c *C, name, blockType string, data []byte) string {
+	path := filepath.Join(s.tmpDir, name)
+	c.Assert(ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: data}), 0600), IsNil)
+	return path
+}
+
+// generateCredentials creates a CA and a certificate for 127.0.0.1
+// signed by it, usable both as client and server.
+func (s *RPCSecuritySuite) generateCredentials(c *C, prefix string) RPCCredentials {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, IsNil)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: prefix + " CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	c.Assert(err, IsNil)
+	ca, err := x509.ParseCertificate(caDER)
+	c.Assert(err, IsNil)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, IsNil)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "leto"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	c.Assert(err, IsNil)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	c.Assert(err, IsNil)
+
+	return RPCCredentials{
+		TLSCA:   s.writePEM(c, prefix+"-ca.pem", "CERTIFICATE", caDER),
+		TLSCert: s.writePEM(c, prefix+"-cert.pem", "CERTIFICATE", der),
+		TLSKey:  s.writePEM(c, prefix+"-key.pem", "EC PRIVATE KEY", keyDER),
+	}
+}
+
+func (s *RPCSecuritySuite) serve(c *C, handler http.Handler, config *tls.Config) (Node, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	if config != nil {
+		l = tls.NewListener(l, config)
+	}
+	server := &http.Server{Handler: handler}
+	go server.Serve(l)
+	return Node{
+		Name:    "test-node",
+		Address: "127.0.0.1",
+		Port:    l.Addr().(*net.TCPAddr).Port,
+	}, func() { server.Close() }
+}
+
+func testRouter(c *C) *rpc.Server {
+	router := rpc.NewServer()
+	c.Assert(router.RegisterName("Test", &RPCTestService{}), IsNil)
+	return router
+}
+
+func (s *RPCSecuritySuite) TestTokenAuthentication(c *C) {
+	tokenFile := filepath.Join(s.tmpDir, "token")
+	c.Assert(ioutil.WriteFile(tokenFile, []byte("secret\n"), 0600), IsNil)
+	credentials := RPCCredentials{TokenFile: tokenFile}
+	token, err := credentials.Token()
+	c.Assert(err, IsNil)
+	c.Check(token, Equals, "secret")
+
+	node, stop := s.serve(c, RequireToken(testRouter(c), token), nil)
+	defer stop()
+
+	msg, reply := "foo", ""
+	client := NewRPCClient()
+	defer client.Close()
+	err = client.Call(context.Background(), node, "Test.Echo", &msg, &reply)
+	c.Check(err, ErrorMatches, `could not connect to 'test-node': unauthorized, invalid or missing token`)
+
+	client.Token = "not-the-secret"
+	err = client.Call(context.Background(), node, "Test.Echo", &msg, &reply)
+	c.Check(err, ErrorMatches, `could not connect to 'test-node': unauthorized, invalid or missing token`)
+
+	c.Assert(credentials.ConfigureClient(client), IsNil)
+	c.Check(client.Call(context.Background(), node, "Test.Echo", &msg, &reply), IsNil)
+	c.Check(reply, Equals, "foo")
+}
+
+func (s *RPCSecuritySuite) TestMutualTLS(c *C) {
+	credentials := s.generateCredentials(c, "lab")
+	serverConfig, err := credentials.ServerTLSConfig()
+	c.Assert(err, IsNil)
+	node, stop := s.serve(c, testRouter(c), serverConfig)
+	defer stop()
+
+	msg, reply := "foo", ""
+	client := NewRPCClient()
+	defer client.Close()
+	c.Assert(credentials.ConfigureClient(client), IsNil)
+	c.Check(client.Call(context.Background(), node, "Test.Echo", &msg, &reply), IsNil)
+	c.Check(reply, Equals, "foo")
+
+	// a certificate from another CA is refused
+	other := NewRPCClient()
+	defer other.Close()
+	c.Assert(s.generateCredentials(c, "student").ConfigureClient(other), IsNil)
+	err = other.Call(context.Background(), node, "Test.Echo", &msg, &reply)
+	c.Check(err, ErrorMatches, `could not connect to 'test-node': .*certificate.*`)
+
+	// as a client without TLS
+	plain := NewRPCClient()
+	defer plain.Close()
+	c.Check(plain.Call(context.Background(), node, "Test.Echo", &msg, &reply), Not(IsNil))
+}
+
+func (s *RPCSecuritySuite) TestCredentialsErrors(c *C) {
+	_, err := RPCCredentials{TLSCert: "foo.pem"}.ServerTLSConfig()
+	c.Check(err, ErrorMatches, `mutual TLS needs a certificate, a key and a CA`)
+	_, err = RPCCredentials{TokenFile: filepath.Join(s.tmpDir, "absent")}.Token()
+	c.Check(err, ErrorMatches, `could not read token: .*`)
+	empty := filepath.Join(s.tmpDir, "empty")
+	c.Assert(ioutil.WriteFile(empty, nil, 0600), IsNil)
+	_, err = RPCCredentials{TokenFile: empty}.Token()
+	c.Check(err, ErrorMatches, `token file '.*' is empty`)
+
+	config, err := RPCCredentials{}.ClientTLSConfig()
+	c.Check(err, IsNil)
+	c.Check(config, IsNil)
+}
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"io/ioutil"
 	"math"
+	"os"
 	"reflect"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v2"
@@ -28,32 +30,238 @@ func MergeConfiguration(from, to interface{}) error {
 		return nil
 	}
 
-	tFrom := reflect.TypeOf(from).Elem()
-	vFrom := reflect.ValueOf(from).Elem()
-	vTo := reflect.ValueOf(to).Elem()
-	for i := 0; i < tFrom.NumField(); i++ {
-		tField := tFrom.Field(i)
-		if tField.Type.Kind() != reflect.Ptr {
-			continue
-		}
+	mergeValue(reflect.ValueOf(from).Elem(), reflect.ValueOf(to).Elem())
 
-		fromField := vFrom.FieldByName(tField.Name)
-		toField := vTo.FieldByName(tField.Name)
+	return nil
+}
 
-		if fromField.Elem().CanSet() == false {
-			continue
+// copyValue returns a copy of v that does not share its slice or map
+// storage.
+func copyValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		res := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		reflect.Copy(res, v)
+		return res
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		res := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for _, k := range v.MapKeys() {
+			res.SetMapIndex(k, copyValue(v.MapIndex(k)))
+		}
+		return res
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
 		}
+		res := reflect.New(v.Type().Elem())
+		res.Elem().Set(copyValue(v.Elem()))
+		return res
+	default:
+		return v
+	}
+}
 
-		if toField.IsNil() {
-			continue
+// mergeValue sets in dst every value set in src. Structs are merged
+// field by field, maps key by key, and pointers to struct are
+// allocated as needed. Nil pointers, maps and slices, and zero
+// values, are considered unset.
+func mergeValue(dst, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Struct:
+		for i := 0; i < src.NumField(); i++ {
+			tField := src.Type().Field(i)
+			if len(tField.PkgPath) != 0 || tField.Tag.Get("merge") == "-" {
+				continue
+			}
+			mergeValue(dst.Field(i), src.Field(i))
+		}
+	case reflect.Ptr:
+		if src.IsNil() {
+			return
+		}
+		if src.Elem().Kind() == reflect.Struct {
+			if dst.IsNil() {
+				dst.Set(reflect.New(src.Type().Elem()))
+			}
+			mergeValue(dst.Elem(), src.Elem())
+			return
 		}
+		dst.Set(copyValue(src))
+	case reflect.Map:
+		if src.IsNil() {
+			return
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.MakeMapWithSize(src.Type(), src.Len()))
+		}
+		for _, k := range src.MapKeys() {
+			dst.SetMapIndex(k, copyValue(src.MapIndex(k)))
+		}
+	case reflect.Slice:
+		if src.IsNil() {
+			return
+		}
+		dst.Set(copyValue(src))
+	default:
+		if src.IsZero() {
+			return
+		}
+		dst.Set(src)
+	}
+}
 
-		fromField.Elem().Set(toField.Elem())
+func yamlFieldName(f reflect.StructField) string {
+	name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+	if len(name) == 0 {
+		return strings.ToLower(f.Name)
+	}
+	return name
+}
+
+// configurationField finds a field using its YAML path, like
+// 'stream.host'. If allocate is true, nil pointers to struct on the
+// path are allocated, otherwise they are walked as zero values.
+func configurationField(v reflect.Value, path string, allocate bool) (reflect.Value, error) {
+	for _, name := range strings.Split(path, ".") {
+		if v.Kind() == reflect.Ptr && v.Type().Elem().Kind() == reflect.Struct {
+			if v.IsNil() == false {
+				v = v.Elem()
+			} else if allocate == true {
+				v.Set(reflect.New(v.Type().Elem()))
+				v = v.Elem()
+			} else {
+				v = reflect.Zero(v.Type().Elem())
+			}
+		}
+		if v.Kind() != reflect.Struct {
+			return reflect.Value{}, fmt.Errorf("unknown field '%s'", path)
+		}
+		found := false
+		for i := 0; i < v.NumField(); i++ {
+			tField := v.Type().Field(i)
+			if len(tField.PkgPath) != 0 || yamlFieldName(tField) != name {
+				continue
+			}
+			v = v.Field(i)
+			found = true
+			break
+		}
+		if found == false {
+			return reflect.Value{}, fmt.Errorf("unknown field '%s'", path)
+		}
 	}
+	return v, nil
+}
 
+// ResetConfiguration sets the fields of config designated by their
+// YAML paths to a copy of their value in defaults.
+func ResetConfiguration(config, defaults interface{}, paths []string) error {
+	if reflect.TypeOf(config) != reflect.TypeOf(defaults) {
+		return fmt.Errorf("Mismatching type %s and %s", reflect.TypeOf(config), reflect.TypeOf(defaults))
+	}
+	if reflect.TypeOf(config).Kind() != reflect.Ptr {
+		return fmt.Errorf("Configuration can only be reset through pointers")
+	}
+	for _, path := range paths {
+		dst, err := configurationField(reflect.ValueOf(config).Elem(), path, true)
+		if err != nil {
+			return err
+		}
+		src, err := configurationField(reflect.ValueOf(defaults).Elem(), path, false)
+		if err != nil {
+			return err
+		}
+		dst.Set(copyValue(src))
+	}
 	return nil
 }
 
+// ConfigurationField is a leaf field of a configuration struct.
+type ConfigurationField struct {
+	// Path is the YAML path of the field, like 'stream.host'.
+	Path  string
+	Field reflect.StructField
+	Value reflect.Value
+}
+
+// ConfigurationFields lists the leaf fields of config, a struct or a
+// pointer to a struct, in declaration order. Nil pointers to struct
+// are walked as zero values. Fields tagged `yaml:"-"` are ignored.
+func ConfigurationFields(config interface{}) ([]ConfigurationField, error) {
+	v := reflect.ValueOf(config)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%s is not a configuration struct", reflect.TypeOf(config))
+	}
+	return configurationFields(v, ""), nil
+}
+
+func configurationFields(v reflect.Value, prefix string) []ConfigurationField {
+	res := []ConfigurationField{}
+	for i := 0; i < v.NumField(); i++ {
+		tField := v.Type().Field(i)
+		if len(tField.PkgPath) != 0 || tField.Tag.Get("yaml") == "-" {
+			continue
+		}
+		path := yamlFieldName(tField)
+		if len(prefix) > 0 {
+			path = prefix + "." + path
+		}
+		field := v.Field(i)
+		if field.Kind() == reflect.Ptr && field.Type().Elem().Kind() == reflect.Struct {
+			if field.IsNil() == true {
+				field = reflect.Zero(field.Type().Elem())
+			} else {
+				field = field.Elem()
+			}
+		}
+		if field.Kind() == reflect.Struct {
+			res = append(res, configurationFields(field, path)...)
+			continue
+		}
+		res = append(res, ConfigurationField{Path: path, Field: tField, Value: field})
+	}
+	return res
+}
+
+// ChangedConfigurationFields returns the YAML paths, like
+// 'stream.host', of the fields that differ between a and b. Fields
+// tagged `merge:"-"` are ignored.
+func ChangedConfigurationFields(a, b interface{}) ([]string, error) {
+	if reflect.TypeOf(a) != reflect.TypeOf(b) {
+		return nil, fmt.Errorf("Mismatching type %s and %s", reflect.TypeOf(a), reflect.TypeOf(b))
+	}
+	if reflect.TypeOf(a).Kind() != reflect.Ptr {
+		return nil, fmt.Errorf("Configuration can only be compared through pointers")
+	}
+	aFields, err := ConfigurationFields(a)
+	if err != nil {
+		return nil, err
+	}
+	bFields, err := ConfigurationFields(b)
+	if err != nil {
+		return nil, err
+	}
+	res := []string{}
+	for i, f := range aFields {
+		if f.Field.Tag.Get("merge") == "-" {
+			continue
+		}
+		if reflect.DeepEqual(f.Value.Interface(), bFields[i].Value.Interface()) == false {
+			res = append(res, f.Path)
+		}
+	}
+	return res, nil
+}
+
 type QuadDetectionConfiguration struct {
 	Decimate        *float64 `long:"at-quad-decimate" description:"Decimate quads (recommended:1.0)" yaml:"decimate"`
 	Sigma           *float64 `long:"at-quad-sigma" description:"Blur before finding quads (recommended:0.0)" yaml:"sigma"`
@@ -110,9 +318,6 @@ func RecommendedDetectionConfig() TagDetectionConfiguration {
 }
 
 func (from *TagDetectionConfiguration) Merge(to *TagDetectionConfiguration) error {
-	if err := from.Quad.Merge(&to.Quad); err != nil {
-		return err
-	}
 	return MergeConfiguration(from, to)
 }
 
@@ -179,7 +384,30 @@ type LoadBalancing struct {
 	Width, Height int
 }
 
+// Copy returns a deep copy of the LoadBalancing, which can be
+// modified for a slave without modifying the master's one.
+func (lb *LoadBalancing) Copy() *LoadBalancing {
+	if lb == nil {
+		return nil
+	}
+	res := *lb
+	if lb.UUIDs != nil {
+		res.UUIDs = make(map[string]string, len(lb.UUIDs))
+		for k, v := range lb.UUIDs {
+			res.UUIDs[k] = v
+		}
+	}
+	if lb.Assignements != nil {
+		res.Assignements = make(map[int]string, len(lb.Assignements))
+		for k, v := range lb.Assignements {
+			res.Assignements[k] = v
+		}
+	}
+	return &res
+}
+
 type TrackingConfiguration struct {
+	SchemaVersion       int                       `yaml:"schema-version"`
 	ExperimentName      string                    `short:"e" long:"experiment" description:"Name of the experiment to run" yaml:"experiment"`
 	LegacyMode          *bool                     `long:"legacy-mode" description:"Produces a legacy mode data output" yaml:"legacy-mode"`
 	NewAntOutputROISize *int                      `long:"new-ant-size" description:"Size of the image when a new ant is found (recommended:600)" yaml:"new-ant-roi"`
@@ -190,6 +418,7 @@ type TrackingConfiguration struct {
 	Highlights          *[]int                    `yaml:"highlights"`
 	Loads               *LoadBalancing            `yaml:"load-balancing"`
 	Threads             *int                      `yaml:"threads"`
+	Reset               []string                  `long:"reset" description:"YAML path of a field to reset to its default value, like stream.host" yaml:"reset,omitempty" merge:"-"`
 }
 
 func RecommendedTrackingConfiguration() TrackingConfiguration {
@@ -210,26 +439,30 @@ func RecommendedTrackingConfiguration() TrackingConfiguration {
 	return res
 }
 
+// Merge sets in from all fields set in to. Fields listed in to.Reset
+// are then reset to their value in from before the merge, like the
+// site configuration of a node, so the defaults are kept.
 func (from *TrackingConfiguration) Merge(to *TrackingConfiguration) error {
-	if err := from.Stream.Merge(&to.Stream); err != nil {
-		return err
-	}
-	if err := from.Camera.Merge(&to.Camera); err != nil {
-		return err
+	if from == nil || to == nil || len(to.Reset) == 0 {
+		return MergeConfiguration(from, to)
 	}
-	if err := from.Detection.Merge(&to.Detection); err != nil {
+	defaults := &TrackingConfiguration{}
+	if err := MergeConfiguration(defaults, from); err != nil {
 		return err
 	}
+	return from.MergeWithDefaults(to, defaults)
+}
 
-	if len(to.ExperimentName) > 0 {
-		from.ExperimentName = to.ExperimentName
+// MergeWithDefaults is like Merge, but fields listed in to.Reset are
+// reset to their value in defaults.
+func (from *TrackingConfiguration) MergeWithDefaults(to, defaults *TrackingConfiguration) error {
+	if err := MergeConfiguration(from, to); err != nil {
+		return err
 	}
-	if from.Loads == nil && to.Loads != nil {
-		from.Loads = &LoadBalancing{}
-		*from.Loads = *to.Loads
+	if to == nil || len(to.Reset) == 0 {
+		return nil
 	}
-
-	return MergeConfiguration(from, to)
+	return ResetConfiguration(from, defaults, to.Reset)
 }
 
 func CheckNoNilField(v reflect.Value) error {
@@ -258,20 +491,50 @@ func (c *TrackingConfiguration) CheckAllFieldAreSet() error {
 	return CheckNoNilField(reflect.ValueOf(*c))
 }
 
-func ReadConfiguration(filename string) (*TrackingConfiguration, error) {
+func parseConfiguration(data []byte, unmarshal func([]byte, interface{}) error) (*TrackingConfiguration, error) {
+	data, err := MigrateConfiguration(data)
+	if err != nil {
+		return nil, err
+	}
+	res := &TrackingConfiguration{}
+	err = unmarshal(data, res)
+	return res, err
+}
+
+// ParseConfiguration parses a YAML configuration, migrating it to
+// the current schema-version if needed.
+func ParseConfiguration(data []byte) (*TrackingConfiguration, error) {
+	return parseConfiguration(data, yaml.Unmarshal)
+}
+
+func readConfiguration(filename string, unmarshal func([]byte, interface{}) error) (*TrackingConfiguration, error) {
 	txt, err := ioutil.ReadFile(filename)
 	if err != nil {
 		return nil, fmt.Errorf("Could not read '%s': %s", filename, err)
 	}
 
-	res := &TrackingConfiguration{}
-	err = yaml.Unmarshal(txt, res)
+	return parseConfiguration(txt, unmarshal)
+}
 
-	return res, err
+func ReadConfiguration(filename string) (*TrackingConfiguration, error) {
+	return readConfiguration(filename, yaml.Unmarshal)
+}
+
+// ReadConfigurationStrict reads a configuration, but reports unknown
+// or duplicated YAML keys as errors.
+func ReadConfigurationStrict(filename string) (*TrackingConfiguration, error) {
+	return readConfiguration(filename, yaml.UnmarshalStrict)
 }
 
 func (c *TrackingConfiguration) Yaml() ([]byte, error) {
-	data, err := yaml.Marshal(c)
+	var versioned *TrackingConfiguration = nil
+	if c != nil {
+		// configurations are always written in the current layout
+		versioned = &TrackingConfiguration{}
+		*versioned = *c
+		versioned.SchemaVersion = CONFIGURATION_SCHEMA_VERSION
+	}
+	data, err := yaml.Marshal(versioned)
 	if err != nil {
 		return nil, fmt.Errorf("Could not encode configuration: %s", err)
 	}
@@ -292,17 +555,54 @@ func (c *TrackingConfiguration) WriteConfiguration(filename string) error {
 	return nil
 }
 
-func LoadDefaultConfig() *TrackingConfiguration {
+// SiteConfigurationError is reported when the site default
+// configuration file exists but cannot be used.
+type SiteConfigurationError struct {
+	Path string
+	Err  error
+}
+
+func (e *SiteConfigurationError) Error() string {
+	return fmt.Sprintf("invalid site configuration '%s': %s", e.Path, e.Err)
+}
+
+// LoadDefaultConfigFile merges filename over the recommended
+// configuration. It returns the modification time of filename, which
+// is zero if the file is absent. A missing file is not an error, but
+// an unreadable, unparsable or invalid file is reported as a
+// *SiteConfigurationError, with RecommendedTrackingConfiguration() as
+// fallback.
+func LoadDefaultConfigFile(filename string) (*TrackingConfiguration, time.Time, error) {
 	res := RecommendedTrackingConfiguration()
-	systemConfig, err := ReadConfiguration("/etc/default/leto.yml")
+	info, err := os.Stat(filename)
+	if err != nil {
+		if os.IsNotExist(err) == true {
+			return &res, time.Time{}, nil
+		}
+		return &res, time.Time{}, &SiteConfigurationError{Path: filename, Err: err}
+	}
+
+	systemConfig, err := ReadConfigurationStrict(filename)
 	if err != nil {
-		return &res
+		return &res, info.ModTime(), &SiteConfigurationError{Path: filename, Err: err}
+	}
+
+	if err := systemConfig.Validate().ToError(); err != nil {
+		return &res, info.ModTime(), &SiteConfigurationError{Path: filename, Err: err}
 	}
 
 	err = res.Merge(systemConfig)
 	if err != nil {
 		res = RecommendedTrackingConfiguration()
+		return &res, info.ModTime(), &SiteConfigurationError{Path: filename, Err: err}
 	}
 
-	return &res
+	return &res, info.ModTime(), nil
+}
+
+// LoadDefaultConfig loads the site default configuration from
+// DEFAULT_CONFIG_PATH.
+func LoadDefaultConfig() (*TrackingConfiguration, error) {
+	res, _, err := LoadDefaultConfigFile(DEFAULT_CONFIG_PATH)
+	return res, err
 }
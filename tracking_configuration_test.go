@@ -78,6 +78,46 @@ func (s *ConfigurationSuite) TestCanBeMerged(c *C) {
 
 }
 
+func (s *ConfigurationSuite) TestChangedFields(c *C) {
+	a := RecommendedTrackingConfiguration()
+	b := RecommendedTrackingConfiguration()
+
+	changed, err := ChangedConfigurationFields(&a, &b)
+	c.Assert(err, IsNil)
+	c.Check(changed, HasLen, 0)
+
+	*b.Stream.BitRateKB = 4000
+	*b.Highlights = []int{1, 2}
+	b.Loads = &LoadBalancing{Width: 640}
+	b.Reset = []string{"threads"}
+
+	changed, err = ChangedConfigurationFields(&a, &b)
+	c.Assert(err, IsNil)
+	c.Check(changed, DeepEquals, []string{"stream.bitrate", "highlights", "load-balancing.width"})
+
+	_, err = ChangedConfigurationFields(a, b)
+	c.Check(err, ErrorMatches, "Configuration can only be compared through pointers")
+}
+
+func (s *ConfigurationSuite) TestListsFieldsWithTheirYAMLPath(c *C) {
+	config := RecommendedTrackingConfiguration()
+	fields, err := ConfigurationFields(&config)
+	c.Assert(err, IsNil)
+	paths := map[string]ConfigurationField{}
+	for _, f := range fields {
+		paths[f.Path] = f
+	}
+	c.Check(paths["stream.bitrate"].Value.Interface(), Equals, config.Stream.BitRateKB)
+	c.Check(paths["stream.host"].Field.Tag.Get("description"), Equals, "host to stream to ")
+	// nil pointers to struct are walked as zero values
+	c.Check(paths["load-balancing.width"].Value.Int(), Equals, int64(0))
+	_, ok := paths["stream"]
+	c.Check(ok, Equals, false)
+
+	_, err = ConfigurationFields(42)
+	c.Check(err, ErrorMatches, "int is not a configuration struct")
+}
+
 func (s *ConfigurationSuite) TestYAMLParsing(c *C) {
 
 	expected := RecommendedTrackingConfiguration()
@@ -241,3 +281,115 @@ func (s *ConfigurationSuite) TestNoNilFieldCheck(c *C) {
 	}
 
 }
+
+func (s *ConfigurationSuite) TestLoadDefaultConfigFile(c *C) {
+	recommended := RecommendedTrackingConfiguration()
+
+	config, modTime, err := LoadDefaultConfigFile(filepath.Join(s.testDir, "does-not-exist.yml"))
+	c.Check(err, IsNil)
+	c.Check(modTime.IsZero(), Equals, true)
+	c.Check(config, DeepEquals, &recommended)
+
+	badConfigPath := filepath.Join(s.testDir, "bad-default.yml")
+	c.Assert(ioutil.WriteFile(badConfigPath, []byte(`:foobar
+`), 0644), IsNil)
+	config, modTime, err = LoadDefaultConfigFile(badConfigPath)
+	c.Check(err, ErrorMatches, `invalid site configuration '.*': yaml: unmarshal errors:
+  line 1:.*`)
+	_, ok := err.(*SiteConfigurationError)
+	c.Check(ok, Equals, true)
+	c.Check(modTime.IsZero(), Equals, false)
+	c.Check(config, DeepEquals, &recommended)
+
+	unknownKeyConfigPath := filepath.Join(s.testDir, "unknown-key-default.yml")
+	c.Assert(ioutil.WriteFile(unknownKeyConfigPath, []byte(`stream:
+  bitrates: 2000
+`), 0644), IsNil)
+	config, _, err = LoadDefaultConfigFile(unknownKeyConfigPath)
+	c.Check(err, ErrorMatches, `(?s)invalid site configuration '.*': .*field bitrates not found.*`)
+	c.Check(config, DeepEquals, &recommended)
+
+	invalidConfigPath := filepath.Join(s.testDir, "invalid-default.yml")
+	c.Assert(ioutil.WriteFile(invalidConfigPath, []byte(`camera:
+  fps: -3
+`), 0644), IsNil)
+	config, _, err = LoadDefaultConfigFile(invalidConfigPath)
+	c.Check(err, ErrorMatches, `invalid site configuration '.*': camera.fps: -3 must be strictly positive`)
+	c.Check(config, DeepEquals, &recommended)
+
+	goodConfigPath := filepath.Join(s.testDir, "good-default.yml")
+	c.Assert(ioutil.WriteFile(goodConfigPath, []byte(`stream:
+  host: olympus
+`), 0644), IsNil)
+	config, modTime, err = LoadDefaultConfigFile(goodConfigPath)
+	c.Check(err, IsNil)
+	c.Check(modTime.IsZero(), Equals, false)
+	c.Check(*config.Stream.Host, Equals, "olympus")
+}
+
+func (s *ConfigurationSuite) TestMergeRecursesInNestedValues(c *C) {
+	from := &TrackingConfiguration{
+		Loads: &LoadBalancing{
+			SelfUUID: "foo",
+			UUIDs:    map[string]string{"localhost": "foo", "slave": "bar"},
+		},
+	}
+	to := &TrackingConfiguration{
+		Loads: &LoadBalancing{
+			UUIDs: map[string]string{"slave": "baz"},
+			Width: 640,
+		},
+		Highlights: &[]int{1, 2},
+	}
+
+	c.Assert(from.Merge(to), IsNil)
+	c.Check(from.Loads, DeepEquals, &LoadBalancing{
+		SelfUUID: "foo",
+		UUIDs:    map[string]string{"localhost": "foo", "slave": "baz"},
+		Width:    640,
+	})
+	c.Check(*from.Highlights, DeepEquals, []int{1, 2})
+	c.Check(from.Detection.Quad.Decimate, IsNil)
+
+	// merged values must not be shared
+	(*to.Highlights)[0] = 42
+	to.Loads.UUIDs["slave"] = "qux"
+	c.Check(*from.Highlights, DeepEquals, []int{1, 2})
+	c.Check(from.Loads.UUIDs["slave"], Equals, "baz")
+}
+
+func (s *ConfigurationSuite) TestMergeCanResetFields(c *C) {
+	site := RecommendedTrackingConfiguration()
+	*site.Stream.Host = "olympus"
+	*site.Detection.Quad.MinBWDiff = 120
+	from := &TrackingConfiguration{}
+	c.Assert(from.Merge(&site), IsNil)
+
+	host := "other"
+	minBWDiff := 80
+	to := &TrackingConfiguration{
+		Stream:    StreamConfiguration{Host: &host},
+		Detection: TagDetectionConfiguration{Quad: QuadDetectionConfiguration{MinBWDiff: &minBWDiff}},
+		Reset:     []string{"stream.host", "apriltag.quad.min-black-white-diff"},
+	}
+	// fields are reset to the configuration merged into
+	c.Assert(from.Merge(to), IsNil)
+	c.Check(*from.Stream.Host, Equals, "olympus")
+	c.Check(*from.Detection.Quad.MinBWDiff, Equals, 120)
+	c.Check(from.Reset, IsNil)
+	c.Check(*site.Stream.Host, Equals, "olympus")
+
+	recommended := RecommendedTrackingConfiguration()
+	c.Assert(from.MergeWithDefaults(to, &recommended), IsNil)
+	c.Check(*from.Stream.Host, Equals, "")
+	c.Check(*from.Detection.Quad.MinBWDiff, Equals, 50)
+
+	// unset fields are reset to nil
+	empty := &TrackingConfiguration{}
+	c.Assert(empty.Merge(to), IsNil)
+	c.Check(empty.Stream.Host, IsNil)
+
+	to.Reset = []string{"stream.hots"}
+	c.Check(from.Merge(to), ErrorMatches, `unknown field 'stream.hots'`)
+	c.Check(to.Validate().ToError(), ErrorMatches, `reset: unknown field 'stream.hots'`)
+}